@@ -0,0 +1,134 @@
+// Package cli implements the goblast command line. Programs that register
+// their own strategies with package strategy call Main from their own main
+// function.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/goblust"
+	"jombG/goblast/internal/history"
+	"jombG/goblast/internal/quarantine"
+	"jombG/goblast/internal/selector"
+)
+
+// Main runs the goblast command named by os.Args and exits on error.
+func Main() {
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("goblast "+command, flag.ExitOnError)
+	opts := registerFlags(fs)
+
+	var err error
+	switch command {
+	case "run":
+		fs.Parse(args)
+		err = goblust.Run(*opts)
+
+	case "watch":
+		interval := fs.Duration("interval", 500*time.Millisecond, "how often to poll for changed files")
+		debounce := fs.Duration("debounce", 300*time.Millisecond, "wait until files are unchanged for this long before running tests")
+		fs.Parse(args)
+		err = goblust.Watch(*opts, goblust.WatchOptions{
+			Interval: *interval,
+			Debounce: *debounce,
+		})
+
+	case "serve":
+		interval := fs.Duration("interval", time.Second, "how often to poll for changed files")
+		fs.Parse(args)
+		err = goblust.Serve(goblust.ServeOptions{
+			Socket:   opts.Socket,
+			Interval: *interval,
+		})
+
+	case "audit":
+		fs.Parse(args)
+		err = goblust.Audit(*opts)
+
+	case "validate":
+		from := fs.String("from", "", "first commit of the range to validate (exclusive)")
+		to := fs.String("to", "HEAD", "last commit of the range to validate")
+		against := fs.String("against", goblust.ReferenceFull, "reference selection: full, or a strategy name such as conservative")
+		fs.Parse(args)
+		if *from == "" {
+			fmt.Fprintln(os.Stderr, "Error: validate requires -from")
+			os.Exit(2)
+		}
+		err = goblust.Validate(*opts, goblust.ValidateOptions{
+			From:      *from,
+			To:        *to,
+			Reference: *against,
+		})
+
+	case "replay":
+		from := fs.String("from", "", "first commit of the range to replay (exclusive)")
+		to := fs.String("to", "HEAD", "last commit of the range to replay")
+		strategies := fs.String("strategies", "", "comma-separated strategies to compare (default: all registered)")
+		format := fs.String("format", "table", "output format: table, csv, json")
+		fs.Parse(args)
+		if *from == "" {
+			fmt.Fprintln(os.Stderr, "Error: replay requires -from")
+			os.Exit(2)
+		}
+		var names []string
+		if *strategies != "" {
+			names = strings.Split(*strategies, ",")
+		}
+		err = goblust.Replay(*opts, goblust.ReplayOptions{
+			From:       *from,
+			To:         *to,
+			Strategies: names,
+			Format:     *format,
+		})
+
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown command: %s\n", command)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func registerFlags(fs *flag.FlagSet) *goblust.Options {
+	opts := &goblust.Options{}
+
+	fs.StringVar(&opts.Base, "base", "main", "base branch for comparison (default: main)")
+	fs.StringVar(&opts.Head, "head", "HEAD", "head commit for comparison")
+	fs.StringVar(&opts.Compare, "compare", "merge-base", "how to compare head with base: direct, merge-base")
+	fs.StringVar(&opts.Changes, "changes", "all", "which changes to consider: committed, staged (planned and tested in a temporary worktree holding the index), worktree, all")
+	fs.StringVar(&opts.DiffFile, "diff", "", "read changes from a unified diff file instead of git (- for stdin)")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print test command without executing")
+	fs.BoolVar(&opts.DebugFiles, "debug-files", false, "print affected Go files")
+	fs.BoolVar(&opts.DebugSymbols, "debug-symbols", false, "print extracted symbols from changed files")
+	fs.BoolVar(&opts.DebugTests, "debug-tests", false, "print discovered test functions from changed files")
+	fs.BoolVar(&opts.DebugTypes, "debug-types", false, "print precise type-based usages of changed symbols in tests")
+	fs.StringVar(&opts.Strategy, "strategy", "package-fallback", "test selection strategy: "+strings.Join(selector.Names(), ", ")+", exec:<command>, or composite:<config.json>")
+	fs.BoolVar(&opts.DebugSelection, "debug-selection", false, "print selected tests based on strategy")
+	fs.IntVar(&opts.ShardIndex, "shard-index", 0, "index of this shard when splitting tests across workers (0-based)")
+	fs.IntVar(&opts.ShardTotal, "shard-total", 1, "total number of shards to split selected tests across")
+	fs.BoolVar(&opts.ShardByDuration, "shard-by-duration", false, "balance shards by recorded test durations instead of by hash; every shard must read the same -history")
+	fs.StringVar(&opts.HistoryFile, "history", history.DefaultPath, "file storing test duration history (empty to disable)")
+	fs.DurationVar(&opts.Budget, "budget", 0, "time budget for selected tests, e.g. 5m; lowest-impact tests are dropped to fit")
+	fs.IntVar(&opts.Retries, "retries", 0, "re-run failed tests up to N times; tests passing on retry are reported as flaky")
+	fs.StringVar(&opts.QuarantineFile, "quarantine", quarantine.DefaultPath, "file listing quarantined tests whose failures do not fail the run")
+	fs.Float64Var(&opts.MinConfidence, "min-confidence", 0, "skip selected tests whose confidence score (0-1) is below this threshold")
+	fs.BoolVar(&opts.FailFast, "fail-fast", false, "stop starting further packages after the first package fails")
+	fs.StringVar(&opts.Generated, "generated", goblust.GeneratedPrecise, "how to treat changed generated files: precise, source (as a package-wide change to the generator input), ignore")
+	fs.BoolVar(&opts.NoDaemon, "no-daemon", false, "plan in-process even if a goblast daemon is running")
+	fs.StringVar(&opts.Socket, "socket", goblust.DefaultSocket, "Unix socket of the goblast daemon (serve listens on it)")
+
+	return opts
+}
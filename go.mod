@@ -3,7 +3,8 @@ module jombG/goblast
 go 1.24.3
 
 require (
-	golang.org/x/mod v0.32.0 // indirect
-	golang.org/x/sync v0.19.0 // indirect
-	golang.org/x/tools v0.41.0 // indirect
+	golang.org/x/mod v0.32.0
+	golang.org/x/tools v0.41.0
 )
+
+require golang.org/x/sync v0.19.0 // indirect
@@ -0,0 +1,209 @@
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+}
+
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Path returns the path the change applies to: the new path, or the old one
+// when the file was deleted.
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+func (f FileDiff) IsDeleted() bool {
+	return f.NewPath == "" && f.OldPath != ""
+}
+
+// Touches reports whether any hunk changes lines in [start, end] of the new
+// file. Pure deletions count as touching the line they were removed before.
+func (f FileDiff) Touches(start, end int) bool {
+	for _, h := range f.Hunks {
+		hunkStart := h.NewStart
+		hunkEnd := h.NewStart + h.NewLines - 1
+		if h.NewLines == 0 {
+			hunkEnd = hunkStart
+		}
+		if hunkStart <= end && start <= hunkEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFile reads a unified diff from path, or from stdin when path is "-".
+func ParseFile(path string) ([]FileDiff, error) {
+	if path == "-" {
+		return Parse(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func Parse(r io.Reader) ([]FileDiff, error) {
+	var files []FileDiff
+	var current *FileDiff
+
+	flush := func() {
+		if current != nil && current.Path() != "" {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	lineNum := 0
+	oldRemaining, newRemaining := 0, 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNum++
+
+		// Hunk bodies are consumed by count so that content lines such as
+		// "--- x" are never mistaken for file headers.
+		if oldRemaining > 0 || newRemaining > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldRemaining--
+			case strings.HasPrefix(line, "+"):
+				newRemaining--
+			case strings.HasPrefix(line, "\\"):
+			default:
+				oldRemaining--
+				newRemaining--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			oldPath, newPath := parseGitHeader(strings.TrimPrefix(line, "diff --git "))
+			current = &FileDiff{OldPath: oldPath, NewPath: newPath}
+
+		case strings.HasPrefix(line, "--- ") && (current == nil || len(current.Hunks) > 0):
+			// A "---" outside of a git header starts a new plain unified diff.
+			flush()
+			current = &FileDiff{OldPath: parseFileLine(line[4:])}
+
+		case strings.HasPrefix(line, "--- ") && current != nil:
+			current.OldPath = parseFileLine(line[4:])
+
+		case strings.HasPrefix(line, "+++ ") && current != nil && len(current.Hunks) == 0:
+			current.NewPath = parseFileLine(line[4:])
+
+		case strings.HasPrefix(line, "rename to ") && current != nil:
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+
+		case strings.HasPrefix(line, "deleted file mode") && current != nil:
+			current.NewPath = ""
+
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			oldRemaining, newRemaining = hunk.OldLines, hunk.NewLines
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	flush()
+	return files, nil
+}
+
+func parseGitHeader(header string) (string, string) {
+	parts := strings.SplitN(header, " b/", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimPrefix(parts[0], "a/"), parts[1]
+}
+
+func parseFileLine(value string) string {
+	if i := strings.IndexByte(value, '\t'); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+
+	if value == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(value, "a/") || strings.HasPrefix(value, "b/") {
+		return value[2:]
+	}
+	return value
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@ section".
+func parseHunkHeader(line string) (Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %q", line)
+	}
+
+	oldStart, oldLines, err := parseRange(fields[1][1:])
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %q", line)
+	}
+	newStart, newLines, err := parseRange(fields[2][1:])
+	if err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header: %q", line)
+	}
+
+	return Hunk{
+		OldStart: oldStart,
+		OldLines: oldLines,
+		NewStart: newStart,
+		NewLines: newLines,
+	}, nil
+}
+
+func parseRange(value string) (int, int, error) {
+	start, count, found := strings.Cut(value, ",")
+
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return s, 1, nil
+	}
+
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s, c, nil
+}
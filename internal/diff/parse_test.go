@@ -0,0 +1,239 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []FileDiff
+	}{
+		{
+			name: "modified file with two hunks",
+			input: `diff --git a/shop/cart.go b/shop/cart.go
+index 1111111..2222222 100644
+--- a/shop/cart.go
++++ b/shop/cart.go
+@@ -3,3 +3,4 @@ import "fmt"
+ func A() {
+-	old()
++	new()
++	more()
+ }
+@@ -20,2 +21,2 @@ func B() {
+-	x := 1
++	x := 2
+ }
+`,
+			want: []FileDiff{{
+				OldPath: "shop/cart.go",
+				NewPath: "shop/cart.go",
+				Hunks: []Hunk{
+					{OldStart: 3, OldLines: 3, NewStart: 3, NewLines: 4},
+					{OldStart: 20, OldLines: 2, NewStart: 21, NewLines: 2},
+				},
+			}},
+		},
+		{
+			name: "hunk content looking like file headers",
+			input: `diff --git a/notes.go b/notes.go
+--- a/notes.go
++++ b/notes.go
+@@ -1,2 +1,2 @@
+--- removed.go
++++ added.go
+ // end
+diff --git a/other.go b/other.go
+--- a/other.go
++++ b/other.go
+@@ -5 +5 @@
+-a
++b
+`,
+			want: []FileDiff{
+				{
+					OldPath: "notes.go",
+					NewPath: "notes.go",
+					Hunks:   []Hunk{{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2}},
+				},
+				{
+					OldPath: "other.go",
+					NewPath: "other.go",
+					Hunks:   []Hunk{{OldStart: 5, OldLines: 1, NewStart: 5, NewLines: 1}},
+				},
+			},
+		},
+		{
+			name: "new file",
+			input: `diff --git a/shop/new.go b/shop/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/shop/new.go
+@@ -0,0 +1,3 @@
++package shop
++
++func New() {}
+`,
+			want: []FileDiff{{
+				NewPath: "shop/new.go",
+				Hunks:   []Hunk{{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 3}},
+			}},
+		},
+		{
+			name: "deleted file",
+			input: `diff --git a/shop/old.go b/shop/old.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/shop/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package shop
+-func Old() {}
+`,
+			want: []FileDiff{{
+				OldPath: "shop/old.go",
+				Hunks:   []Hunk{{OldStart: 1, OldLines: 2, NewStart: 0, NewLines: 0}},
+			}},
+		},
+		{
+			name: "pure rename",
+			input: `diff --git a/shop/a.go b/shop/b.go
+similarity index 100%
+rename from shop/a.go
+rename to shop/b.go
+`,
+			want: []FileDiff{{
+				OldPath: "shop/a.go",
+				NewPath: "shop/b.go",
+			}},
+		},
+		{
+			name: "plain unified diff with timestamps",
+			input: `--- a/calc.go	2024-01-01 10:00:00.000000000 +0000
++++ b/calc.go	2024-01-02 10:00:00.000000000 +0000
+@@ -1,1 +1,1 @@
+-x
++y
+--- util.go
++++ util.go
+@@ -7,0 +8,1 @@
++z
+`,
+			want: []FileDiff{
+				{
+					OldPath: "calc.go",
+					NewPath: "calc.go",
+					Hunks:   []Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1}},
+				},
+				{
+					OldPath: "util.go",
+					NewPath: "util.go",
+					Hunks:   []Hunk{{OldStart: 7, OldLines: 0, NewStart: 8, NewLines: 1}},
+				},
+			},
+		},
+		{
+			name: "no newline at end of file",
+			input: `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1 +1 @@
+-old
+\ No newline at end of file
++new
+\ No newline at end of file
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -2 +2 @@
+-1
++2
+`,
+			want: []FileDiff{
+				{
+					OldPath: "a.go",
+					NewPath: "a.go",
+					Hunks:   []Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1}},
+				},
+				{
+					OldPath: "b.go",
+					NewPath: "b.go",
+					Hunks:   []Hunk{{OldStart: 2, OldLines: 1, NewStart: 2, NewLines: 1}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMalformedHunk(t *testing.T) {
+	input := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -x +1 @@
+`
+	if _, err := Parse(strings.NewReader(input)); err == nil {
+		t.Error("Parse() error = nil; want error for malformed hunk header")
+	}
+}
+
+func TestParseGitHeader(t *testing.T) {
+	tests := []struct {
+		header  string
+		oldPath string
+		newPath string
+	}{
+		{"a/shop/cart.go b/shop/cart.go", "shop/cart.go", "shop/cart.go"},
+		{"a/old/name.go b/new/name.go", "old/name.go", "new/name.go"},
+		{"a/dir with space/x.go b/dir with space/x.go", "dir with space/x.go", "dir with space/x.go"},
+		{"no-prefixes", "", ""},
+	}
+
+	for _, tt := range tests {
+		oldPath, newPath := parseGitHeader(tt.header)
+		if oldPath != tt.oldPath || newPath != tt.newPath {
+			t.Errorf("parseGitHeader(%q) = %q, %q; want %q, %q", tt.header, oldPath, newPath, tt.oldPath, tt.newPath)
+		}
+	}
+}
+
+func TestTouches(t *testing.T) {
+	fd := FileDiff{Hunks: []Hunk{
+		{OldStart: 10, OldLines: 3, NewStart: 10, NewLines: 4},
+		{OldStart: 30, OldLines: 2, NewStart: 31, NewLines: 0},
+	}}
+
+	tests := []struct {
+		start, end int
+		want       bool
+	}{
+		{1, 9, false},
+		{5, 10, true},
+		{13, 20, true},
+		{14, 20, false},
+		{31, 31, true},
+		{32, 40, false},
+	}
+
+	for _, tt := range tests {
+		if got := fd.Touches(tt.start, tt.end); got != tt.want {
+			t.Errorf("Touches(%d, %d) = %v; want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package goblust
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"jombG/goblast/internal/modules"
+	"jombG/goblast/internal/mutation"
+	"jombG/goblast/internal/selector"
+)
+
+type mutantOutcome int
+
+const (
+	// mutantKilled means a selected test failed on the mutant.
+	mutantKilled mutantOutcome = iota
+	// mutantMissed means the selected tests passed but another test of the
+	// mutated package failed: selection missed a test that matters.
+	mutantMissed
+	// mutantSurvived means no test of the package noticed the mutant.
+	mutantSurvived
+	// mutantInvalid means the mutant did not build, or only tests already
+	// failing before mutation cover it.
+	mutantInvalid
+)
+
+type auditResult struct {
+	Mutant  mutation.Mutant
+	Outcome mutantOutcome
+}
+
+// Audit mutates each changed function in a temporary copy of the workspace
+// and runs the selected tests against every mutant. Mutants surviving the
+// selection are re-checked with the full test set of the mutated package;
+// those it kills are selection misses. Tests failing before mutation are
+// excluded, so they cannot kill mutants. Audit fails when any mutant was
+// missed.
+func Audit(opts Options) error {
+	if opts.Changes == "staged" && opts.DiffFile == "" {
+		restore, err := enterStagedWorktree(&opts)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	changes, err := loadChanges(opts)
+	if err != nil {
+		return err
+	}
+	if len(changes.GoFiles) == 0 {
+		fmt.Println("No Go files changed. Nothing to audit.")
+		return nil
+	}
+
+	// A patch already narrows symbols to its hunks; git changes are compared
+	// declaration by declaration so unchanged functions are not mutated.
+	targets := changes.Symbols
+	if opts.DiffFile == "" {
+		readPrevious, err := previousSourceReader(opts)
+		if err != nil {
+			return err
+		}
+		targets = onlyChangedDeclarations(targets, readPrevious, nil)
+	}
+
+	var mutants []mutation.Mutant
+	for _, sym := range targets {
+		if strings.HasSuffix(sym.File, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(sym.File)
+		if err != nil {
+			continue
+		}
+		symMutants, err := mutation.Generate(sym, src)
+		if err != nil {
+			continue
+		}
+		mutants = append(mutants, symMutants...)
+	}
+	if len(mutants) == 0 {
+		fmt.Println("No mutants could be generated for changed functions.")
+		return nil
+	}
+
+	ws, err := modules.Discover(".")
+	if err != nil {
+		return fmt.Errorf("failed to discover modules: %w", err)
+	}
+
+	plan, err := obtainPlan(opts, changes.GoFiles, changes.Symbols, nil)
+	if err != nil {
+		return err
+	}
+
+	copyRoot, err := os.MkdirTemp("", "goblast-audit-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(copyRoot)
+
+	if err := copyTree(ws.Root, copyRoot); err != nil {
+		return fmt.Errorf("failed to copy workspace: %w", err)
+	}
+	inCopy := func(path string) string {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return path
+		}
+		rel, err := filepath.Rel(ws.Root, abs)
+		if err != nil {
+			return path
+		}
+		return filepath.Join(copyRoot, rel)
+	}
+
+	broken, err := baselineFailures(ws, plan.Selected, mutants, inCopy)
+	if err != nil {
+		return err
+	}
+	var healthy []selector.TestID
+	for _, id := range plan.Selected {
+		if !broken[id] {
+			healthy = append(healthy, id)
+		}
+	}
+	if len(broken) > 0 {
+		var names []string
+		for id := range broken {
+			names = append(names, id.Package+"."+id.TestName)
+		}
+		slices.Sort(names)
+		fmt.Printf("Excluding %d tests failing before mutation:\n", len(names))
+		for _, name := range names {
+			fmt.Printf("  - %s\n", name)
+		}
+	}
+
+	fmt.Printf("Auditing %d mutants against %d selected tests...\n", len(mutants), len(healthy))
+
+	order, byPackage := groupByPackage(healthy)
+
+	var results []auditResult
+	for _, m := range mutants {
+		target := inCopy(m.File)
+		original, err := os.ReadFile(target)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, m.Source, 0o644); err != nil {
+			return err
+		}
+
+		outcome := mutantInvalid
+		if !coveredOnlyBy(broken, m, plan) {
+			outcome = runMutant(ws, m, order, byPackage, broken, inCopy)
+		}
+		results = append(results, auditResult{Mutant: m, Outcome: outcome})
+
+		if err := os.WriteFile(target, original, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(formatAudit(results))
+
+	missed := 0
+	for _, r := range results {
+		if r.Outcome == mutantMissed {
+			missed++
+		}
+	}
+	if missed > 0 {
+		return fmt.Errorf("selection missed %d of %d mutants", missed, len(results))
+	}
+	return nil
+}
+
+func runMutant(ws *modules.Workspace, m mutation.Mutant, order []string, byPackage map[string][]string, broken map[selector.TestID]bool, inCopy func(string) string) mutantOutcome {
+	for _, pkg := range order {
+		results, err := runPackageTests(inCopy(moduleDir(ws, pkg)), pkg, byPackage[pkg], io.Discard)
+		if err == nil {
+			continue
+		}
+		if len(failedTests(results)) > 0 {
+			return mutantKilled
+		}
+		return mutantInvalid
+	}
+
+	results, err := runTestsJSON(inCopy(moduleDir(ws, m.Symbol.Package)), []string{m.Symbol.Package}, io.Discard)
+	if err == nil {
+		return mutantSurvived
+	}
+	failing := failedTests(results)
+	if len(failing) == 0 {
+		return mutantInvalid
+	}
+	for _, id := range failing {
+		if !broken[id] {
+			return mutantMissed
+		}
+	}
+	return mutantSurvived
+}
+
+// baselineFailures runs the selected tests and the tests of every mutated
+// package on the unmutated copy, and returns the tests that already fail.
+func baselineFailures(ws *modules.Workspace, selected []selector.TestID, mutants []mutation.Mutant, inCopy func(string) string) (map[selector.TestID]bool, error) {
+	broken := make(map[selector.TestID]bool)
+	record := func(pkg string, results []testResult, err error) error {
+		if err == nil {
+			return nil
+		}
+		failing := failedTests(results)
+		if len(failing) == 0 {
+			return fmt.Errorf("tests of %s fail to build before mutation: %w", pkg, err)
+		}
+		for _, id := range failing {
+			broken[id] = true
+		}
+		return nil
+	}
+
+	order, byPackage := groupByPackage(selected)
+	for _, pkg := range order {
+		results, err := runPackageTests(inCopy(moduleDir(ws, pkg)), pkg, byPackage[pkg], io.Discard)
+		if err := record(pkg, results, err); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range mutants {
+		pkg := m.Symbol.Package
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		results, err := runTestsJSON(inCopy(moduleDir(ws, pkg)), []string{pkg}, io.Discard)
+		if err := record(pkg, results, err); err != nil {
+			return nil, err
+		}
+	}
+
+	return broken, nil
+}
+
+// coveredOnlyBy reports whether every selected test covering m is in broken.
+// Tests using the mutated symbol cover it; without any, the selected tests of
+// its package do.
+func coveredOnlyBy(broken map[selector.TestID]bool, m mutation.Mutant, plan *Plan) bool {
+	covering := make(map[selector.TestID]bool)
+	for _, u := range plan.Usages {
+		if u.SymbolName == m.Symbol.Name && u.SymbolKind == m.Symbol.Kind {
+			id := selector.TestID{Package: u.TestPackage, TestName: u.TestName}
+			if slices.Contains(plan.Selected, id) {
+				covering[id] = true
+			}
+		}
+	}
+	if len(covering) == 0 {
+		for _, id := range plan.Selected {
+			if id.Package == m.Symbol.Package {
+				covering[id] = true
+			}
+		}
+	}
+
+	if len(covering) == 0 {
+		return false
+	}
+	for id := range covering {
+		if !broken[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyTree copies the regular files below src into dst, skipping hidden
+// directories such as .git.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if path != src && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
+
+func formatAudit(results []auditResult) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Mutation Audit ===\n\n")
+
+	counts := make(map[mutantOutcome]int)
+	for _, r := range results {
+		counts[r.Outcome]++
+		if r.Outcome != mutantMissed {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  missed: %s:%d %s in %s\n",
+			r.Mutant.File, r.Mutant.Line, r.Mutant.Description, r.Mutant.Symbol.Name))
+	}
+	if counts[mutantMissed] > 0 {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("Mutants: %d\n", len(results)))
+	sb.WriteString(fmt.Sprintf("Killed by selected tests: %d\n", counts[mutantKilled]))
+	sb.WriteString(fmt.Sprintf("Missed by selection (killed by package tests): %d\n", counts[mutantMissed]))
+	sb.WriteString(fmt.Sprintf("Survived all package tests: %d\n", counts[mutantSurvived]))
+	sb.WriteString(fmt.Sprintf("Invalid (did not build, or only failing tests cover it): %d\n", counts[mutantInvalid]))
+
+	if detectable := counts[mutantKilled] + counts[mutantMissed]; detectable > 0 {
+		sb.WriteString(fmt.Sprintf("Selection accuracy: %.1f%%\n", 100*float64(counts[mutantKilled])/float64(detectable)))
+	}
+
+	return sb.String()
+}
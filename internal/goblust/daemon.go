@@ -0,0 +1,219 @@
+package goblust
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"jombG/goblast/internal/symbols"
+)
+
+const DefaultSocket = ".goblast/daemon.sock"
+
+// daemonDialTimeout bounds how long the CLI waits for a daemon before falling
+// back to planning in-process.
+const daemonDialTimeout = 200 * time.Millisecond
+
+type ServeOptions struct {
+	Socket string
+	// Interval is how often the daemon polls for changed files to keep its
+	// caches warm.
+	Interval time.Duration
+}
+
+// PlanRequest asks a daemon for the plan of a set of changed files. Root must
+// match the daemon's root, since file paths are relative to it.
+type PlanRequest struct {
+	Root     string
+	Strategy string
+	Files    []string
+	Symbols  []symbols.Symbol
+}
+
+// PlanService is the JSON-RPC service exposed by `goblast serve`.
+type PlanService struct {
+	root     string
+	planner  *planner
+	mu       sync.Mutex
+	snapshot snapshot
+}
+
+func (s *PlanService) Plan(req PlanRequest, resp *Plan) error {
+	if req.Root != s.root {
+		return fmt.Errorf("daemon serves %s, not %s", s.root, req.Root)
+	}
+
+	// Pick up edits the background poll has not seen yet.
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	plan, err := s.planner.plan(req.Strategy, req.Files, req.Symbols)
+	if err != nil {
+		return err
+	}
+	*resp = *plan
+	return nil
+}
+
+// refresh invalidates cached data for files changed since the last snapshot.
+func (s *PlanService) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := takeSnapshot(".", s.snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+
+	if changed := changedPaths(s.snapshot, current); len(changed) > 0 {
+		s.planner.invalidate(changed)
+	}
+	s.snapshot = current
+	return nil
+}
+
+// Serve runs a daemon answering plan requests on a Unix socket. It keeps the
+// import graph, test inventory and loaded packages in memory and invalidates
+// them incrementally as files change.
+func Serve(opts ServeOptions) error {
+	if opts.Socket == "" {
+		opts.Socket = DefaultSocket
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+
+	p, err := newPlanner()
+	if err != nil {
+		return err
+	}
+
+	initial, err := takeSnapshot(".", nil)
+	if err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+
+	service := &PlanService{root: root, planner: p, snapshot: initial}
+	server := rpc.NewServer()
+	if err := server.Register(service); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.Socket), 0o755); err != nil {
+		return err
+	}
+	// A socket left behind by a daemon that did not shut down cleanly would
+	// make Listen fail.
+	if _, err := net.DialTimeout("unix", opts.Socket, daemonDialTimeout); err == nil {
+		return fmt.Errorf("a daemon is already listening on %s", opts.Socket)
+	}
+	os.Remove(opts.Socket)
+
+	listener, err := net.Listen("unix", opts.Socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(opts.Socket)
+
+	signals := make(chan os.Signal, 1)
+	// Closing the listener returns from Serve, which removes the socket. A
+	// hangup, as when the terminal running the daemon closes, shuts down too.
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	go func() {
+		for {
+			time.Sleep(opts.Interval)
+			if err := service.refresh(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+	}()
+
+	fmt.Printf("goblast daemon serving %s on %s\n", root, opts.Socket)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// planFromDaemon asks a running daemon for a plan. It returns an error when
+// no daemon is reachable, so callers can plan in-process instead.
+func planFromDaemon(socket string, req PlanRequest) (*Plan, error) {
+	conn, err := net.DialTimeout("unix", socket, daemonDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client := jsonrpc.NewClient(conn)
+	defer client.Close()
+
+	var plan Plan
+	if err := client.Call("PlanService.Plan", req, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// obtainPlan uses p when given, otherwise a daemon listening on opts.Socket,
+// otherwise a fresh in-process planner.
+func obtainPlan(opts Options, goFiles []string, extractedSymbols []symbols.Symbol, p *planner) (*Plan, error) {
+	if p != nil {
+		return p.plan(opts.Strategy, goFiles, extractedSymbols)
+	}
+
+	if !opts.NoDaemon {
+		socket := opts.Socket
+		if socket == "" {
+			socket = DefaultSocket
+		}
+		if root, err := filepath.Abs("."); err == nil {
+			plan, err := planFromDaemon(socket, PlanRequest{
+				Root:     root,
+				Strategy: opts.Strategy,
+				Files:    goFiles,
+				Symbols:  extractedSymbols,
+			})
+			if err == nil {
+				return plan, nil
+			}
+			// No daemon at the default socket is the common case and not
+			// worth a warning, even when a killed daemon left its socket.
+			noDaemon := errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED)
+			if socket != DefaultSocket || !noDaemon {
+				fmt.Fprintf(os.Stderr, "Warning: no usable daemon on %s (%v); planning in-process.\n", socket, err)
+			}
+		}
+	}
+
+	p, err := newPlanner()
+	if err != nil {
+		return nil, err
+	}
+	return p.plan(opts.Strategy, goFiles, extractedSymbols)
+}
@@ -0,0 +1,119 @@
+package goblust
+
+import (
+	"os"
+	"slices"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// narrowStructChanges compares the structs of changed files with their
+// previous revision. Only fields whose declarations changed are kept, along
+// with fields that were removed, and a struct's type symbol only when its
+// header changed. When fields were only reordered, the fields that moved are
+// kept so that unkeyed composite literals of the struct are still selected.
+// Other symbols are kept as extracted.
+func narrowStructChanges(syms []symbols.Symbol, readPrevious, read symbols.SourceReader) []symbols.Symbol {
+	structs := make(map[string]bool)
+	for _, sym := range syms {
+		if sym.Kind == "field" {
+			structs[sym.File+"|"+sym.Receiver] = true
+		}
+	}
+	if len(structs) == 0 {
+		return syms
+	}
+
+	if read == nil {
+		read = os.ReadFile
+	}
+
+	isStructPart := func(sym symbols.Symbol) bool {
+		return (sym.Kind == "field" && structs[sym.File+"|"+sym.Receiver]) ||
+			(sym.Kind == "type" && structs[sym.File+"|"+sym.Name])
+	}
+
+	changed := make(map[string]map[string]bool)
+	extracted := make(map[string]bool)
+	var removed []symbols.Symbol
+	for _, sym := range syms {
+		extracted[symbolKey(sym)] = true
+	}
+	currentLayout := fieldLayout(syms)
+	moved := make(map[string]bool)
+
+	for _, sym := range syms {
+		if !isStructPart(sym) {
+			continue
+		}
+		if _, ok := changed[sym.File]; ok {
+			continue
+		}
+
+		fileChanges, err := declarationsChanged(sym.File, readPrevious, read)
+		if err != nil {
+			continue
+		}
+		changed[sym.File] = make(map[string]bool)
+		for _, c := range fileChanges {
+			changed[sym.File][symbolKey(c)] = true
+			if c.Kind == "field" && !extracted[symbolKey(c)] {
+				removed = append(removed, c)
+			}
+		}
+
+		if previous, err := readPrevious(sym.File); err == nil {
+			old := extractFromSnapshot(snapshot{sym.File: {content: append([]byte{}, previous...)}}, sym.File)
+			for key, fields := range fieldLayout(old) {
+				for _, name := range movedFields(fields, currentLayout[key]) {
+					moved[key+"."+name] = true
+				}
+			}
+		}
+	}
+
+	var result []symbols.Symbol
+	for _, sym := range syms {
+		fileChanges, compared := changed[sym.File]
+		if !isStructPart(sym) || !compared || fileChanges[symbolKey(sym)] ||
+			(sym.Kind == "field" && moved[sym.File+"|"+sym.Receiver+"."+sym.Name]) {
+			result = append(result, sym)
+		}
+	}
+
+	return append(result, removed...)
+}
+
+// fieldLayout lists the field names of each struct in declaration order,
+// keyed by file and struct name.
+func fieldLayout(syms []symbols.Symbol) map[string][]string {
+	layout := make(map[string][]string)
+	for _, sym := range syms {
+		if sym.Kind == "field" {
+			key := sym.File + "|" + sym.Receiver
+			layout[key] = append(layout[key], sym.Name)
+		}
+	}
+	return layout
+}
+
+// movedFields returns the fields whose position changed when previous and
+// current hold the same fields in a different order. Added or removed fields
+// are changed symbols already, so other layouts yield nothing.
+func movedFields(previous, current []string) []string {
+	if len(previous) != len(current) || slices.Equal(previous, current) {
+		return nil
+	}
+	sorted := slices.Sorted(slices.Values(previous))
+	if !slices.Equal(sorted, slices.Sorted(slices.Values(current))) {
+		return nil
+	}
+
+	var moved []string
+	for i, name := range current {
+		if previous[i] != name {
+			moved = append(moved, name)
+		}
+	}
+	return moved
+}
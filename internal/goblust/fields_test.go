@@ -0,0 +1,121 @@
+package goblust
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"jombG/goblast/internal/symbols"
+)
+
+func TestNarrowStructChanges(t *testing.T) {
+	const previous = `package shop
+
+type Product struct {
+	ID    int
+	Name  string
+	Price float64
+}
+`
+
+	tests := []struct {
+		name    string
+		current string
+		want    []string
+	}{
+		{
+			name: "field added",
+			current: `package shop
+
+type Product struct {
+	ID    int
+	Name  string
+	Price float64
+	Stock int
+}
+`,
+			want: []string{"field Product.Stock"},
+		},
+		{
+			name: "fields reordered",
+			current: `package shop
+
+type Product struct {
+	Name  string
+	ID    int
+	Price float64
+}
+`,
+			want: []string{"field Product.Name", "field Product.ID"},
+		},
+		{
+			name: "field removed",
+			current: `package shop
+
+type Product struct {
+	ID    int
+	Price float64
+}
+`,
+			want: []string{"field Product.Name"},
+		},
+		{
+			name: "field type changed",
+			current: `package shop
+
+type Product struct {
+	ID    int
+	Name  string
+	Price int
+}
+`,
+			want: []string{"field Product.Price"},
+		},
+		{
+			name: "struct unchanged",
+			current: `package shop
+
+type Product struct {
+	ID    int
+	Name  string
+	Price float64
+}
+
+func Touch() {}
+`,
+			want: []string{"func Touch"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "product.go")
+			if err := os.WriteFile(file, []byte(tt.current), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			syms, err := symbols.ExtractFromFiles([]string{file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			readPrevious := func(string) ([]byte, error) { return []byte(previous), nil }
+
+			var got []string
+			for _, sym := range narrowStructChanges(syms, readPrevious, nil) {
+				name := sym.Name
+				if sym.Kind == "field" {
+					name = sym.Receiver + "." + sym.Name
+				}
+				got = append(got, sym.Kind+" "+name)
+			}
+
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("narrowStructChanges() = %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package goblust
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// Policies for changes to generated files, which are rewritten wholesale on
+// regeneration and would otherwise report every declaration as changed.
+const (
+	// GeneratedPrecise keeps only declarations whose text changed.
+	GeneratedPrecise = "precise"
+	// GeneratedSource treats a changed generated file as a package-wide
+	// change to its generator input, found from the file's header or the
+	// //go:generate directive producing it. Files whose input is unknown
+	// behave as with GeneratedPrecise.
+	GeneratedSource = "source"
+	// GeneratedIgnore drops generated files entirely.
+	GeneratedIgnore = "ignore"
+)
+
+func applyGeneratedPolicy(opts Options, syms []symbols.Symbol, readSource symbols.SourceReader) ([]symbols.Symbol, error) {
+	policy := opts.Generated
+	if policy == "" {
+		policy = GeneratedPrecise
+	}
+	if policy != GeneratedPrecise && policy != GeneratedSource && policy != GeneratedIgnore {
+		return nil, fmt.Errorf("unknown generated code policy: %s", policy)
+	}
+
+	byFile := make(map[string][]symbols.Symbol)
+	var result []symbols.Symbol
+	for _, sym := range syms {
+		if !sym.Generated {
+			result = append(result, sym)
+			continue
+		}
+		byFile[sym.File] = append(byFile[sym.File], sym)
+	}
+	if len(byFile) == 0 || policy == GeneratedIgnore {
+		return result, nil
+	}
+
+	read := readSource
+	if read == nil {
+		read = os.ReadFile
+	}
+
+	// Patches are already narrowed to the declarations their hunks touch.
+	var readPrevious symbols.SourceReader
+	if opts.DiffFile == "" {
+		var err error
+		readPrevious, err = previousSourceReader(opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for file, fileSyms := range byFile {
+		current, err := read(file)
+		if err != nil {
+			result = append(result, fileSyms...)
+			continue
+		}
+
+		if policy == GeneratedSource {
+			if input := existingInput(generatorInputs(file, current)); input != "" {
+				result = append(result, symbols.Symbol{
+					Package:     fileSyms[0].Package,
+					Name:        filepath.Base(input),
+					Kind:        "generate",
+					Position:    filepath.Base(file) + ":1",
+					File:        input,
+					PackageWide: true,
+					Generated:   true,
+				})
+				continue
+			}
+		}
+
+		if readPrevious == nil {
+			result = append(result, fileSyms...)
+			continue
+		}
+
+		precise, err := declarationsChanged(file, readPrevious, read)
+		if err != nil {
+			result = append(result, fileSyms...)
+			continue
+		}
+		result = append(result, precise...)
+	}
+
+	return result, nil
+}
+
+// previousSourceReader reads files as they were before the changes selected
+// by opts.Changes.
+func previousSourceReader(opts Options) (symbols.SourceReader, error) {
+	var rev string
+	switch opts.Changes {
+	case "staged":
+		rev = "HEAD"
+	case "worktree":
+		rev = ""
+	default:
+		from, err := resolveCompareBase(opts.Base, opts.Head, opts.Compare)
+		if err != nil {
+			return nil, err
+		}
+		rev = from
+	}
+
+	return func(path string) ([]byte, error) {
+		cmd := exec.Command("git", "show", rev+":./"+filepath.ToSlash(path))
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git show failed for %s: %w", path, err)
+		}
+		return output, nil
+	}, nil
+}
+
+// generatorInputs returns the files a generated file was produced from: the
+// source named in its header, and any //go:generate directive in the same
+// directory mentioning it, along with the directive's file arguments.
+func generatorInputs(file string, src []byte) []string {
+	dir := filepath.Dir(file)
+	base := filepath.Base(file)
+
+	var inputs []string
+	if source := symbols.GeneratorSource(src); source != "" {
+		inputs = append(inputs, filepath.Join(dir, source), filepath.Clean(source))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return inputs
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == base || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			directive, ok := strings.CutPrefix(line, "//go:generate ")
+			if !ok || !strings.Contains(directive, base) {
+				continue
+			}
+
+			inputs = append(inputs, filepath.Join(dir, name))
+			for _, arg := range strings.Fields(directive) {
+				if _, value, ok := strings.Cut(arg, "="); ok {
+					arg = value
+				}
+				if arg == base {
+					continue
+				}
+				if info, err := os.Stat(filepath.Join(dir, arg)); err == nil && !info.IsDir() {
+					inputs = append(inputs, filepath.Join(dir, arg))
+				}
+			}
+		}
+	}
+
+	return inputs
+}
+
+// existingInput returns the first of inputs that exists on disk.
+func existingInput(inputs []string) string {
+	for _, input := range inputs {
+		if info, err := os.Stat(input); err == nil && !info.IsDir() {
+			return input
+		}
+	}
+	return ""
+}
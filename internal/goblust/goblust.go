@@ -1,100 +1,316 @@
 package goblust
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"jombG/goblast/internal/diff"
+	"jombG/goblast/internal/history"
+	"jombG/goblast/internal/modules"
+	"jombG/goblast/internal/quarantine"
 	"jombG/goblast/internal/selector"
 	"jombG/goblast/internal/symbols"
 	"jombG/goblast/internal/tests"
 	"jombG/goblast/internal/usage"
 )
 
-func Run(base, head string, dryRun, debugFiles, debugSymbols, debugTests, debugTypes bool, strategyName string, debugSelection bool) error {
-	var changedFiles []string
+type Options struct {
+	Base            string
+	Head            string
+	Compare         string
+	Changes         string
+	DiffFile        string
+	DryRun          bool
+	DebugFiles      bool
+	DebugSymbols    bool
+	DebugTests      bool
+	DebugTypes      bool
+	Strategy        string
+	DebugSelection  bool
+	ShardIndex      int
+	ShardTotal      int
+	ShardByDuration bool
+	HistoryFile     string
+	Budget          time.Duration
+	Retries         int
+	QuarantineFile  string
+	NoDaemon        bool
+	Socket          string
+	MinConfidence   float64
+	FailFast        bool
+	Generated       string
+}
 
-	committedFiles, err := getChangedFiles(base, head)
-	if err != nil {
-		return fmt.Errorf("failed to get changed files: %w", err)
+// recentFailureWindow is how long a failed test keeps running ahead of other
+// tests with the same confidence.
+const recentFailureWindow = 7 * 24 * time.Hour
+
+// quarantineStablePasses is how many consecutive passes make a quarantined
+// test a candidate for removal from the quarantine list.
+const quarantineStablePasses = 10
+
+func Run(opts Options) error {
+	if opts.Changes == "staged" && opts.DiffFile == "" {
+		restore, err := enterStagedWorktree(&opts)
+		if err != nil {
+			return err
+		}
+		defer restore()
 	}
-	changedFiles = append(changedFiles, committedFiles...)
 
-	uncommittedFiles, err := getUncommittedFiles()
+	changes, err := loadChanges(opts)
 	if err != nil {
-		return fmt.Errorf("failed to get uncommitted files: %w", err)
+		return err
 	}
-	changedFiles = append(changedFiles, uncommittedFiles...)
-
-	changedFiles = deduplicateFiles(changedFiles)
-
-	goFiles := filterGoFiles(changedFiles)
-	if debugFiles {
+	if opts.DebugFiles {
 		fmt.Println("Affected Go files:")
-		for _, f := range goFiles {
+		for _, f := range changes.GoFiles {
 			fmt.Printf("  %s\n", f)
 		}
+		for _, t := range changes.Targets {
+			fmt.Printf("  %s (go:generate input in %s)\n", t.Input, t.Directive)
+		}
 		fmt.Println()
 	}
-	if len(goFiles) == 0 {
+	if len(changes.GoFiles) == 0 {
 		fmt.Println("No Go files changed. Nothing to test.")
 		return nil
 	}
+	if opts.DebugSymbols {
+		fmt.Println(symbols.FormatSymbols(changes.Symbols))
+	}
 
-	extractedSymbols, err := symbols.ExtractFromFiles(goFiles)
-	if err != nil {
-		return fmt.Errorf("failed to extract symbols: %w", err)
+	return runPipeline(opts, changes.GoFiles, changes.Symbols, nil)
+}
+
+// narrowPackageWide clears PackageWide on TestMain, init and variable
+// symbols whose declaration text did not change, so editing another function
+// in their file does not select every test of the package.
+func narrowPackageWide(syms []symbols.Symbol, readPrevious, read symbols.SourceReader) []symbols.Symbol {
+	// Generator inputs are not Go declarations and stay package-wide.
+	declared := func(sym symbols.Symbol) bool {
+		return sym.PackageWide && sym.Kind != "generate"
 	}
-	if debugSymbols {
-		fmt.Println(symbols.FormatSymbols(extractedSymbols))
+
+	var wide []symbols.Symbol
+	for _, sym := range syms {
+		if declared(sym) {
+			wide = append(wide, sym)
+		}
+	}
+	if len(wide) == 0 {
+		return syms
+	}
+
+	// init may be declared once per file, so keys include the file.
+	changed := make(map[string]bool)
+	for _, sym := range onlyChangedDeclarations(wide, readPrevious, read) {
+		changed[sym.File+"|"+symbolKey(sym)] = true
+	}
+	for i := range syms {
+		if declared(syms[i]) && !changed[syms[i].File+"|"+symbolKey(syms[i])] {
+			syms[i].PackageWide = false
+		}
+	}
+	return syms
+}
+
+// loadChanges reads the changes selected by opts, from the -diff patch or
+// from git, and computes the symbols they change.
+func loadChanges(opts Options) (*changeSet, error) {
+	if opts.DiffFile != "" {
+		fileDiffs, err := diff.ParseFile(opts.DiffFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse diff: %w", err)
+		}
+		return computeChanges(opts, filesFromDiff(fileDiffs), fileDiffs)
 	}
 
-	packages, err := mapFilesToPackages(goFiles)
+	changedFiles, err := collectChangedFiles(opts)
 	if err != nil {
-		return fmt.Errorf("failed to map files to packages: %w", err)
+		return nil, err
 	}
+	return computeChanges(opts, changedFiles, nil)
+}
 
-	if len(packages) == 0 {
-		fmt.Println("No testable packages found for changed files.")
-		return nil
+// changeSet describes what a set of changed files means for test selection.
+type changeSet struct {
+	// GoFiles are the changed Go files plus the files declaring go:generate
+	// directives whose inputs changed.
+	GoFiles []string
+	Symbols []symbols.Symbol
+	Targets []generateTarget
+}
+
+// computeChanges extracts the symbols changed in changedFiles. With
+// fileDiffs, symbols are narrowed to the hunks of the patch; otherwise
+// declarations are compared with the revision opts compares against. run,
+// validate, replay and audit all plan from its result.
+func computeChanges(opts Options, changedFiles []string, fileDiffs []diff.FileDiff) (*changeSet, error) {
+	changes := newChangeSet(changedFiles)
+	if len(changes.GoFiles) == 0 && len(changes.Targets) == 0 {
+		return changes, nil
 	}
 
-	uniquePackages := deduplicate(packages)
+	// A patch describes the working tree files, not the index.
+	var readSource symbols.SourceReader
+	if opts.Changes == "staged" && fileDiffs == nil {
+		readSource = readIndexFile
+	}
 
-	dependentPackages, err := findDependentPackages(uniquePackages)
+	extractedSymbols, err := symbols.ExtractFromFilesWithReader(changes.GoFiles, readSource)
 	if err != nil {
-		dependentPackages = []string{}
+		return nil, fmt.Errorf("failed to extract symbols: %w", err)
+	}
+	if fileDiffs != nil {
+		extractedSymbols = filterSymbolsByHunks(extractedSymbols, fileDiffs)
+	} else {
+		readPrevious, err := previousSourceReader(opts)
+		if err != nil {
+			return nil, err
+		}
+		extractedSymbols = narrowStructChanges(extractedSymbols, readPrevious, readSource)
+		extractedSymbols = narrowPackageWide(extractedSymbols, readPrevious, readSource)
 	}
 
-	allPackagesToTest := deduplicate(append(uniquePackages, dependentPackages...))
+	if err := completeChanges(opts, changes, extractedSymbols, readSource); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
 
-	discoveredTests, err := tests.DiscoverFromPackages(allPackagesToTest)
+// newChangeSet splits changed files into Go files and go:generate targets,
+// warning about targets whose generated code was not regenerated.
+func newChangeSet(changedFiles []string) *changeSet {
+	changes := &changeSet{
+		GoFiles: filterGoFiles(changedFiles),
+		Targets: findGenerateTargets(changedFiles),
+	}
+	warnStaleGenerated(changes.Targets, changedFiles)
+	return changes
+}
+
+// completeChanges applies the -generated policy to the changed symbols syms
+// and adds the go:generate targets of changes as package-wide symbols.
+func completeChanges(opts Options, changes *changeSet, syms []symbols.Symbol, readSource symbols.SourceReader) error {
+	syms, err := applyGeneratedPolicy(opts, syms, readSource)
 	if err != nil {
-		return fmt.Errorf("failed to discover tests: %w", err)
+		return err
 	}
-	if debugTests {
-		fmt.Println(tests.FormatTests(discoveredTests))
+
+	generateSyms, directiveFiles := generateSymbols(changes.Targets)
+	changes.Symbols = append(syms, generateSyms...)
+	changes.GoFiles = deduplicateFiles(append(changes.GoFiles, directiveFiles...))
+	return nil
+}
+
+// runPipeline selects and runs the tests affected by changes to goFiles, given
+// the symbols that changed in them. Plans come from p when it is non-nil, or
+// from a running daemon, or from a fresh planner.
+func runPipeline(opts Options, goFiles []string, extractedSymbols []symbols.Symbol, p *planner) error {
+	ws, err := modules.Discover(".")
+	if err != nil {
+		return fmt.Errorf("failed to discover modules: %w", err)
 	}
 
-	detectedUsages, err := usage.DetectUsages(discoveredTests, extractedSymbols)
+	plan, err := obtainPlan(opts, goFiles, extractedSymbols, p)
 	if err != nil {
-		return fmt.Errorf("failed to detect usages: %w", err)
+		return err
 	}
-	if debugTypes {
-		fmt.Println(usage.FormatUsages(detectedUsages))
+
+	if len(plan.Packages) == 0 {
+		fmt.Println("No testable packages found for changed files.")
+		return nil
 	}
 
-	strategy, err := selector.GetStrategy(strategyName)
-	if err != nil {
-		return fmt.Errorf("failed to get strategy: %w", err)
+	if opts.DebugTests {
+		fmt.Println(tests.FormatTests(plan.Tests))
+	}
+	if opts.DebugTypes {
+		fmt.Println(usage.FormatUsages(plan.Usages))
 	}
 
-	selectedTests := strategy.Select(extractedSymbols, discoveredTests, detectedUsages)
+	detectedUsages := plan.Usages
+	selectedTests := plan.Selected
+
+	var store *history.Store
+	if opts.HistoryFile != "" {
+		store, err = history.Load(opts.HistoryFile)
+		if err != nil {
+			return fmt.Errorf("failed to load history: %w", err)
+		}
+	}
 
-	if debugSelection {
-		fmt.Println(selector.FormatSelection(strategy.Name(), selectedTests))
+	var durations map[selector.TestID]time.Duration
+	if store != nil {
+		durations = store.Durations()
+	}
+
+	var quarantined map[selector.TestID]quarantine.Entry
+	if opts.QuarantineFile != "" {
+		list, err := quarantine.Load(opts.QuarantineFile)
+		if err != nil {
+			return fmt.Errorf("failed to load quarantine list: %w", err)
+		}
+		now := time.Now()
+		for _, entry := range list.Expired(now) {
+			fmt.Fprintf(os.Stderr, "Warning: quarantine entry for %s.%s (owner: %s) expired on %s; its failures count again.\n",
+				entry.Package, entry.Test, entry.Owner, entry.Expires)
+		}
+		quarantined = list.Active(now)
+	}
+
+	scores := selector.ScoreConfidence(selectedTests, extractedSymbols, detectedUsages, plan.Importers)
+	if opts.MinConfidence > 0 {
+		var below []selector.TestID
+		selectedTests, below = selector.FilterByConfidence(selectedTests, scores, opts.MinConfidence, plan.alwaysSelected())
+		if len(below) > 0 {
+			fmt.Println(selector.FormatBelowConfidence(below, opts.MinConfidence))
+		}
+	}
+
+	if opts.ShardTotal > 1 || opts.ShardIndex != 0 {
+		var shardDurations map[selector.TestID]time.Duration
+		if opts.ShardByDuration {
+			shardDurations = durations
+		}
+		selectedTests, err = selector.Shard(selectedTests, opts.ShardIndex, opts.ShardTotal, shardDurations)
+		if err != nil {
+			return fmt.Errorf("failed to shard tests: %w", err)
+		}
+	}
+
+	if opts.Budget > 0 {
+		expected, unknown := selector.ExpectedDuration(selectedTests, durations)
+		if len(durations) == 0 {
+			fmt.Println("No test duration history yet; budget not applied.")
+		} else if expected > opts.Budget {
+			if unknown > 0 {
+				fmt.Printf("%d selected tests have no recorded duration; assuming the median of recorded tests.\n", unknown)
+			}
+			ranks := selector.RankByImpact(selectedTests, extractedSymbols, detectedUsages)
+			var dropped []selector.TestID
+			selectedTests, dropped = selector.FitBudget(selectedTests, ranks, durations, opts.Budget)
+			if len(dropped) > 0 {
+				fmt.Println(selector.FormatDropped(dropped, opts.Budget))
+			}
+		}
+	}
+
+	var recentlyFailed map[selector.TestID]bool
+	if store != nil {
+		recentlyFailed = store.FailedSince(time.Now().Add(-recentFailureWindow))
+	}
+	selectedTests = selector.Prioritize(selectedTests, scores, recentlyFailed)
+
+	if opts.DebugSelection {
+		fmt.Println(selector.FormatSelection(plan.Strategy, selectedTests, plan.sourceMap()))
+		fmt.Println(selector.FormatConfidence(selectedTests, scores, recentlyFailed))
 	}
 
 	if len(selectedTests) == 0 {
@@ -102,18 +318,68 @@ func Run(base, head string, dryRun, debugFiles, debugSymbols, debugTests, debugT
 		return nil
 	}
 
-	testCmd := buildTestCommandFromSelection(selectedTests)
+	testCmd := buildTestCommandFromSelection(ws, selectedTests)
 
-	if dryRun {
+	if opts.DryRun {
 		fmt.Println(testCmd)
 		return nil
 	}
 
-	return executeSelectedTests(selectedTests)
+	return executeSelectedTests(ws, selectedTests, store, opts.Retries, opts.FailFast, quarantined)
 }
 
-func getChangedFiles(base, head string) ([]string, error) {
-	cmd := exec.Command("git", "diff", "--name-only", base, head)
+func collectChangedFiles(opts Options) ([]string, error) {
+	var changedFiles []string
+
+	switch opts.Changes {
+	case "committed":
+		committedFiles, err := getChangedFiles(opts.Base, opts.Head, opts.Compare)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changed files: %w", err)
+		}
+		changedFiles = append(changedFiles, committedFiles...)
+
+	case "staged":
+		stagedFiles, err := getStagedFiles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get staged files: %w", err)
+		}
+		changedFiles = append(changedFiles, stagedFiles...)
+
+	case "worktree":
+		worktreeFiles, err := getWorktreeFiles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get working tree files: %w", err)
+		}
+		changedFiles = append(changedFiles, worktreeFiles...)
+
+	case "all", "":
+		committedFiles, err := getChangedFiles(opts.Base, opts.Head, opts.Compare)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changed files: %w", err)
+		}
+		changedFiles = append(changedFiles, committedFiles...)
+
+		uncommittedFiles, err := getUncommittedFiles()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get uncommitted files: %w", err)
+		}
+		changedFiles = append(changedFiles, uncommittedFiles...)
+
+	default:
+		return nil, fmt.Errorf("unknown changes mode: %s", opts.Changes)
+	}
+
+	return deduplicateFiles(changedFiles), nil
+}
+
+func getChangedFiles(base, head, compare string) ([]string, error) {
+	from, err := resolveCompareBase(base, head, compare)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "diff", "--name-only", from, head)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("git diff failed: %w", err)
@@ -127,6 +393,25 @@ func getChangedFiles(base, head string) ([]string, error) {
 	return lines, nil
 }
 
+// resolveCompareBase returns the revision to diff head against. In merge-base
+// mode this is the common ancestor of base and head, so commits that landed
+// on base after branching are not reported as changes (three-dot semantics).
+func resolveCompareBase(base, head, compare string) (string, error) {
+	switch compare {
+	case "direct":
+		return base, nil
+	case "merge-base", "":
+		cmd := exec.Command("git", "merge-base", base, head)
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("git merge-base failed: %w", err)
+		}
+		return strings.TrimSpace(string(output)), nil
+	default:
+		return "", fmt.Errorf("unknown compare mode: %s", compare)
+	}
+}
+
 func getUncommittedFiles() ([]string, error) {
 	// Get both staged and unstaged changes
 	cmd := exec.Command("git", "diff", "--name-only", "HEAD")
@@ -143,6 +428,152 @@ func getUncommittedFiles() ([]string, error) {
 	return lines, nil
 }
 
+func getStagedFiles() ([]string, error) {
+	return gitDiffNames("--cached")
+}
+
+func getWorktreeFiles() ([]string, error) {
+	return gitDiffNames()
+}
+
+func gitDiffNames(args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--name-only"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}, nil
+	}
+
+	return lines, nil
+}
+
+// readIndexFile returns the staged contents of a file, so symbols are
+// extracted from what is about to be committed rather than the working tree.
+func readIndexFile(path string) ([]byte, error) {
+	cmd := exec.Command("git", "show", ":"+filepath.ToSlash(path))
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show failed for %s: %w", path, err)
+	}
+	return output, nil
+}
+
+// enterStagedWorktree checks out HEAD in a temporary worktree, stages the
+// staged changes there and changes into it, so packages are loaded and tests
+// run against the index rather than against unstaged edits. Relative paths in
+// opts, including those of composite and external strategies, are resolved
+// first. The returned function restores the working
+// directory and removes the worktree.
+func enterStagedWorktree(opts *Options) (func(), error) {
+	patch, err := exec.Command("git", "diff", "--cached", "--binary").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+
+	origDir, subdir, err := repoSubdir()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range []*string{&opts.HistoryFile, &opts.QuarantineFile} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(origDir, *path)
+		}
+	}
+	opts.Strategy = absStrategyPaths(opts.Strategy, origDir)
+	// A daemon plans for the working tree.
+	opts.NoDaemon = true
+
+	cleanup, err := enterCommitWorktree("HEAD", subdir)
+	if err != nil {
+		return nil, err
+	}
+	restore := func() {
+		os.Chdir(origDir)
+		cleanup()
+	}
+
+	if len(patch) > 0 {
+		topLevel, err := gitOutput("rev-parse", "--show-toplevel")
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		cmd := exec.Command("git", "apply", "--index", "--whitespace=nowarn")
+		cmd.Dir = topLevel
+		cmd.Stdin = bytes.NewReader(patch)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			restore()
+			return nil, fmt.Errorf("failed to stage changes in worktree: %w: %s", err, output)
+		}
+	}
+
+	return restore, nil
+}
+
+// absStrategyPaths resolves the config path of a composite strategy and the
+// relative files named by an external strategy's command against dir.
+func absStrategyPaths(strategy, dir string) string {
+	if configPath, ok := strings.CutPrefix(strategy, "composite:"); ok && !filepath.IsAbs(configPath) {
+		return "composite:" + filepath.Join(dir, configPath)
+	}
+	command, ok := strings.CutPrefix(strategy, "exec:")
+	if !ok {
+		return strategy
+	}
+
+	fields := strings.Fields(command)
+	for i, field := range fields {
+		if filepath.IsAbs(field) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, field)); err == nil {
+			fields[i] = filepath.Join(dir, field)
+		}
+	}
+	return "exec:" + strings.Join(fields, " ")
+}
+
+func filesFromDiff(fileDiffs []diff.FileDiff) []string {
+	var files []string
+	for _, fd := range fileDiffs {
+		files = append(files, fd.Path())
+	}
+	return deduplicateFiles(files)
+}
+
+// filterSymbolsByHunks keeps only symbols whose declarations overlap a hunk of
+// the patch, which is expected to be applied to the working tree. When a file
+// changed outside any declaration (imports, comments, package-level vars) all
+// of its symbols are kept, so the change is not lost.
+func filterSymbolsByHunks(syms []symbols.Symbol, fileDiffs []diff.FileDiff) []symbols.Symbol {
+	byPath := make(map[string]diff.FileDiff)
+	for _, fd := range fileDiffs {
+		byPath[filepath.Clean(fd.Path())] = fd
+	}
+
+	touched := make(map[string]bool)
+	for _, sym := range syms {
+		fd, ok := byPath[filepath.Clean(sym.File)]
+		if !ok || fd.Touches(sym.Line, sym.EndLine) {
+			touched[sym.File] = true
+		}
+	}
+
+	var filtered []symbols.Symbol
+	for _, sym := range syms {
+		fd, ok := byPath[filepath.Clean(sym.File)]
+		if !ok || !touched[sym.File] || fd.Touches(sym.Line, sym.EndLine) {
+			filtered = append(filtered, sym)
+		}
+	}
+
+	return filtered
+}
+
 func filterGoFiles(files []string) []string {
 	var goFiles []string
 	for _, file := range files {
@@ -160,9 +591,10 @@ func mapFilesToPackages(goFiles []string) ([]string, error) {
 	var packages []string
 
 	for _, file := range goFiles {
-		dir := filepath.Dir(file)
-
-		cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", "./"+dir)
+		// Listing from the package directory resolves the owning module, which
+		// is not necessarily the module of the current directory.
+		cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".")
+		cmd.Dir = filepath.Dir(file)
 		output, err := cmd.Output()
 		if err != nil {
 			continue
@@ -226,18 +658,33 @@ func executeTestCommand(packages []string) error {
 	return nil
 }
 
-func buildTestCommandFromSelection(selected []selector.TestID) string {
-	// Group tests by package
+// groupByPackage groups selected tests by package, listing packages in the
+// order their first test appears in selected.
+func groupByPackage(selected []selector.TestID) ([]string, map[string][]string) {
+	var order []string
 	byPackage := make(map[string][]string)
 	for _, test := range selected {
+		if _, ok := byPackage[test.Package]; !ok {
+			order = append(order, test.Package)
+		}
 		byPackage[test.Package] = append(byPackage[test.Package], test.TestName)
 	}
+	return order, byPackage
+}
+
+func buildTestCommandFromSelection(ws *modules.Workspace, selected []selector.TestID) string {
+	order, byPackage := groupByPackage(selected)
 
 	// Build command string
 	var parts []string
-	for pkg, testNames := range byPackage {
+	for _, pkg := range order {
+		testNames := byPackage[pkg]
 		testPattern := strings.Join(testNames, "|")
-		parts = append(parts, fmt.Sprintf("go test %s -run '^(%s)$'", pkg, testPattern))
+		testCmd := fmt.Sprintf("go test %s -run '^(%s)$'", pkg, testPattern)
+		if mod := ws.ModuleForPackage(pkg); mod != nil && ws.RelDir(mod) != "." {
+			testCmd = fmt.Sprintf("(cd %s && %s)", ws.RelDir(mod), testCmd)
+		}
+		parts = append(parts, testCmd)
 	}
 
 	if len(parts) == 1 {
@@ -247,27 +694,94 @@ func buildTestCommandFromSelection(selected []selector.TestID) string {
 	return strings.Join(parts, " && ")
 }
 
-func executeSelectedTests(selected []selector.TestID) error {
-	// Group tests by package
-	byPackage := make(map[string][]string)
-	for _, test := range selected {
-		byPackage[test.Package] = append(byPackage[test.Package], test.TestName)
-	}
+// executeSelectedTests runs packages in the order their first test appears
+// in selected. With failFast, no further packages are started after one fails.
+func executeSelectedTests(ws *modules.Workspace, selected []selector.TestID, store *history.Store, retries int, failFast bool, quarantined map[selector.TestID]quarantine.Entry) error {
+	order, byPackage := groupByPackage(selected)
 
-	// Execute tests for each package, collecting errors
+	// Execute tests for each package in selection order, collecting errors
 	var failedPackages []string
-	for pkg, testNames := range byPackage {
-		testPattern := "^(" + strings.Join(testNames, "|") + ")$"
+	var passed, flaky, failed, quarantinedFailed []selector.TestID
+	var skippedPackages []string
+	for i, pkg := range order {
+		if failFast && len(failedPackages) > 0 {
+			skippedPackages = order[i:]
+			break
+		}
 
-		cmd := exec.Command("go", "test", pkg, "-run", testPattern)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		testNames := byPackage[pkg]
+		dir := moduleDir(ws, pkg)
 
-		if err := cmd.Run(); err != nil {
+		results, err := runPackageTests(dir, pkg, testNames, os.Stdout)
+		for _, result := range results {
+			if result.Passed {
+				passed = append(passed, result.ID)
+			}
+			if store != nil {
+				store.RecordDuration(result.ID, result.Duration)
+			}
+		}
+		if err == nil {
+			continue
+		}
+
+		failing := failedTests(results)
+		if len(failing) == 0 {
+			// The package failed without a failing test (build error, TestMain
+			// exit code); retrying individual tests cannot help.
 			failedPackages = append(failedPackages, pkg)
+			continue
+		}
+
+		pkgFlaky, pkgFailed := retryFailedTests(dir, pkg, failing, retries)
+		flaky = append(flaky, pkgFlaky...)
+
+		genuine := false
+		for _, id := range pkgFailed {
+			if _, ok := quarantined[id]; ok {
+				quarantinedFailed = append(quarantinedFailed, id)
+				continue
+			}
+			failed = append(failed, id)
+			genuine = true
+		}
+		if genuine {
+			failedPackages = append(failedPackages, pkg)
+		}
+	}
+
+	if store != nil {
+		now := time.Now()
+		for _, id := range passed {
+			store.RecordPass(id)
+		}
+		for _, id := range flaky {
+			store.RecordFlake(id, now)
+		}
+		for _, id := range append(failed, quarantinedFailed...) {
+			store.RecordFailure(id, now)
+		}
+		if err := store.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save test history: %v\n", err)
 		}
 	}
 
+	if len(flaky) > 0 {
+		fmt.Println(formatTestList("Flaky tests (passed on retry)", flaky))
+	}
+	if len(quarantinedFailed) > 0 {
+		fmt.Println(formatQuarantinedFailures(quarantinedFailed, quarantined))
+	}
+	if len(failed) > 0 {
+		fmt.Println(formatTestList("Failed tests", failed))
+	}
+	if store != nil {
+		reportStableQuarantined(store, quarantined)
+	}
+	if len(skippedPackages) > 0 {
+		fmt.Printf("Fail-fast: skipped %d packages: %s\n", len(skippedPackages), strings.Join(skippedPackages, ", "))
+	}
+
 	if len(failedPackages) > 0 {
 		return fmt.Errorf("go test failed for packages: %s", strings.Join(failedPackages, ", "))
 	}
@@ -275,48 +789,88 @@ func executeSelectedTests(selected []selector.TestID) error {
 	return nil
 }
 
-func findDependentPackages(changedPackages []string) ([]string, error) {
-	if len(changedPackages) == 0 {
-		return nil, nil
+func failedTests(results []testResult) []selector.TestID {
+	var failing []selector.TestID
+	for _, result := range results {
+		if !result.Passed {
+			failing = append(failing, result.ID)
+		}
 	}
+	return failing
+}
 
-	cmd := exec.Command("go", "list", "./...")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("go list failed: %w", err)
-	}
+// retryFailedTests re-runs failing tests up to retries times. Tests that pass
+// on a retry are flaky; those still failing after the last attempt are
+// genuine failures.
+func retryFailedTests(dir, pkg string, failing []selector.TestID, retries int) ([]selector.TestID, []selector.TestID) {
+	var flaky []selector.TestID
 
-	allPackages := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(allPackages) == 1 && allPackages[0] == "" {
-		return nil, nil
-	}
+	for attempt := 1; attempt <= retries && len(failing) > 0; attempt++ {
+		fmt.Printf("Retrying %d failed tests in %s (attempt %d/%d)\n", len(failing), pkg, attempt, retries)
 
-	changedSet := make(map[string]struct{})
-	for _, pkg := range changedPackages {
-		changedSet[pkg] = struct{}{}
-	}
+		var names []string
+		for _, id := range failing {
+			names = append(names, id.TestName)
+		}
 
-	var dependentPackages []string
+		results, _ := runPackageTests(dir, pkg, names, os.Stdout)
 
-	for _, pkg := range allPackages {
-		if _, ok := changedSet[pkg]; ok {
-			continue
+		passed := make(map[selector.TestID]bool)
+		for _, result := range results {
+			if result.Passed {
+				passed[result.ID] = true
+			}
 		}
 
-		cmd := exec.Command("go", "list", "-f", "{{range .Imports}}{{.}}\n{{end}}{{range .TestImports}}{{.}}\n{{end}}", pkg)
-		output, err := cmd.Output()
-		if err != nil {
-			continue
+		var stillFailing []selector.TestID
+		for _, id := range failing {
+			if passed[id] {
+				flaky = append(flaky, id)
+			} else {
+				stillFailing = append(stillFailing, id)
+			}
 		}
+		failing = stillFailing
+	}
 
-		imports := strings.Split(strings.TrimSpace(string(output)), "\n")
-		for _, imp := range imports {
-			if _, ok := changedSet[imp]; ok {
-				dependentPackages = append(dependentPackages, pkg)
-				break
-			}
+	return flaky, failing
+}
+
+func formatQuarantinedFailures(ids []selector.TestID, quarantined map[selector.TestID]quarantine.Entry) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Quarantined failures (not failing the run) ===\n\n")
+	for _, id := range ids {
+		entry := quarantined[id]
+		sb.WriteString(fmt.Sprintf("  - %s.%s (owner: %s, expires %s)\n", id.Package, id.TestName, entry.Owner, entry.Expires))
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal: %d tests\n", len(ids)))
+	return sb.String()
+}
+
+// reportStableQuarantined suggests removing quarantine entries for tests that
+// have kept passing since they were last seen failing.
+func reportStableQuarantined(store *history.Store, quarantined map[selector.TestID]quarantine.Entry) {
+	for id, entry := range quarantined {
+		if passes := store.ConsecutivePasses(id); passes >= quarantineStablePasses {
+			fmt.Printf("Suggestion: remove %s.%s from quarantine (owner: %s); it passed %d consecutive runs.\n",
+				id.Package, id.TestName, entry.Owner, passes)
 		}
 	}
+}
 
-	return dependentPackages, nil
+func formatTestList(title string, ids []selector.TestID) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== %s ===\n\n", title))
+	for _, id := range ids {
+		sb.WriteString(fmt.Sprintf("  - %s.%s\n", id.Package, id.TestName))
+	}
+	sb.WriteString(fmt.Sprintf("\nTotal: %d tests\n", len(ids)))
+	return sb.String()
+}
+
+func moduleDir(ws *modules.Workspace, pkg string) string {
+	if mod := ws.ModuleForPackage(pkg); mod != nil {
+		return mod.Dir
+	}
+	return ""
 }
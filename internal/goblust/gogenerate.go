@@ -0,0 +1,152 @@
+package goblust
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// generateTarget links a changed generator input, such as a .proto or .sql
+// file, to a //go:generate directive consuming it.
+type generateTarget struct {
+	Input     string
+	Directive string
+	Line      int
+}
+
+// findGenerateTargets scans the //go:generate directives below the current
+// directory for arguments naming changed non-Go files. Arguments are resolved
+// relative to the directive's directory and may be glob patterns.
+func findGenerateTargets(changedFiles []string) []generateTarget {
+	changed := make(map[string]string)
+	for _, file := range changedFiles {
+		if !strings.HasSuffix(file, ".go") {
+			changed[filepath.Clean(file)] = file
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	var targets []generateTarget
+	filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != "." && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || !bytes.Contains(data, []byte("//go:generate")) {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for line := 1; scanner.Scan(); line++ {
+			directive, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "//go:generate ")
+			if !ok {
+				continue
+			}
+			for _, input := range directiveInputs(dir, directive) {
+				if original, ok := changed[input]; ok {
+					targets = append(targets, generateTarget{Input: original, Directive: path, Line: line})
+				}
+			}
+		}
+		return nil
+	})
+
+	return targets
+}
+
+// directiveInputs returns the paths named by a directive's arguments,
+// including values of -flag=value arguments and expanded glob patterns.
+func directiveInputs(dir, directive string) []string {
+	var inputs []string
+	for _, arg := range strings.Fields(directive) {
+		if _, value, ok := strings.Cut(arg, "="); ok {
+			arg = value
+		}
+		arg = strings.Trim(arg, `"'`)
+		if arg == "" || strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		path := filepath.Join(dir, arg)
+		if strings.ContainsAny(arg, "*?[") {
+			matches, _ := filepath.Glob(path)
+			inputs = append(inputs, matches...)
+			continue
+		}
+		inputs = append(inputs, filepath.Clean(path))
+	}
+	return inputs
+}
+
+// generateSymbols turns generate targets into package-wide symbols of the
+// packages owning their directives. It also returns the directive files, so
+// the packages are part of the plan.
+func generateSymbols(targets []generateTarget) ([]symbols.Symbol, []string) {
+	var syms []symbols.Symbol
+	var files []string
+
+	for _, target := range targets {
+		pkgs, _ := mapFilesToPackages([]string{target.Directive})
+		if len(pkgs) == 0 {
+			continue
+		}
+		syms = append(syms, symbols.Symbol{
+			Package:     pkgs[0],
+			Name:        filepath.Base(target.Input),
+			Kind:        "generate",
+			Position:    fmt.Sprintf("%s:%d", filepath.Base(target.Directive), target.Line),
+			File:        target.Input,
+			PackageWide: true,
+		})
+		files = append(files, target.Directive)
+	}
+
+	return syms, deduplicateFiles(files)
+}
+
+// warnStaleGenerated warns about generate targets whose directory has no
+// changed generated file: the input changed but its output was not
+// regenerated, so tests run against stale code.
+func warnStaleGenerated(targets []generateTarget, changedFiles []string) {
+	regenerated := make(map[string]bool)
+	for _, file := range changedFiles {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err == nil && symbols.IsGenerated(file, src) {
+			regenerated[filepath.Dir(filepath.Clean(file))] = true
+		}
+	}
+
+	warned := make(map[string]bool)
+	for _, target := range targets {
+		dir := filepath.Dir(target.Directive)
+		if regenerated[dir] || warned[target.Input+"|"+dir] {
+			continue
+		}
+		warned[target.Input+"|"+dir] = true
+		fmt.Fprintf(os.Stderr, "Warning: %s changed but no generated file in %s did; run go generate (%s:%d).\n",
+			target.Input, dir, target.Directive, target.Line)
+	}
+}
@@ -0,0 +1,303 @@
+package goblust
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"jombG/goblast/internal/modules"
+	"jombG/goblast/internal/selector"
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/tests"
+	"jombG/goblast/internal/usage"
+)
+
+// Plan is the result of test selection for a set of changed files.
+type Plan struct {
+	Packages  []string
+	Importers map[string][]string
+	Tests     []tests.Test
+	Usages    []usage.Usage
+	Strategy  string
+	Selected  []selector.TestID
+	// Sources lists the sub-strategies that selected each test, for
+	// strategies that report them.
+	Sources []TestSources
+}
+
+type TestSources struct {
+	Test    selector.TestID
+	Sources []string
+}
+
+func (p *Plan) sourceMap() map[selector.TestID][]string {
+	if len(p.Sources) == 0 {
+		return nil
+	}
+	result := make(map[selector.TestID][]string)
+	for _, ts := range p.Sources {
+		result[ts.Test] = ts.Sources
+	}
+	return result
+}
+
+// alwaysSelected returns the tests a composite strategy selects on every run.
+func (p *Plan) alwaysSelected() map[selector.TestID]bool {
+	always := make(map[selector.TestID]bool)
+	for _, ts := range p.Sources {
+		if slices.Contains(ts.Sources, selector.SourceAlways) {
+			always[ts.Test] = true
+		}
+	}
+	return always
+}
+
+// planner computes plans. It caches each module's import graph, the test
+// inventory of each package and loaded type information, so long-running
+// modes only redo work for what changed. A planner is safe for concurrent use.
+type planner struct {
+	mu     sync.Mutex
+	ws     *modules.Workspace
+	cache  *usage.Cache
+	graphs map[string]map[string][]string
+	tests  map[string][]tests.Test
+	// changedTests adds tests whose own function changed to every plan, so
+	// watch mode re-runs a test as it is edited.
+	changedTests bool
+}
+
+func newPlanner() (*planner, error) {
+	ws, err := modules.Discover(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover modules: %w", err)
+	}
+
+	return &planner{
+		ws:     ws,
+		cache:  usage.NewCache(),
+		graphs: make(map[string]map[string][]string),
+		tests:  make(map[string][]tests.Test),
+	}, nil
+}
+
+func (p *planner) plan(strategyName string, goFiles []string, extractedSymbols []symbols.Symbol) (*Plan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	strategy, err := selector.GetStrategy(strategyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %w", err)
+	}
+
+	packages, err := mapFilesToPackages(goFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map files to packages: %w", err)
+	}
+
+	plan := &Plan{
+		Packages: deduplicate(packages),
+		Strategy: strategy.Name(),
+	}
+	if len(plan.Packages) == 0 {
+		return plan, nil
+	}
+
+	dependentPackages, importers, err := p.findDependentPackages(plan.Packages)
+	if err != nil {
+		dependentPackages = []string{}
+	}
+	plan.Importers = importers
+
+	allPackagesToTest := deduplicate(append(plan.Packages, dependentPackages...))
+
+	plan.Tests, err = p.discoverTests(allPackagesToTest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover tests: %w", err)
+	}
+
+	plan.Usages, err = usage.DetectUsagesCached(plan.Tests, extractedSymbols, p.cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect usages: %w", err)
+	}
+
+	selected := strategy.Select(extractedSymbols, plan.Tests, plan.Usages)
+	if reporter, ok := strategy.(selector.SourceReporter); ok {
+		for id, sources := range reporter.Sources() {
+			plan.Sources = append(plan.Sources, TestSources{Test: id, Sources: sources})
+		}
+	}
+	selected = selector.WithPackageWide(selected, extractedSymbols, plan.Tests, importers)
+	if p.changedTests {
+		selected = selector.WithChangedTests(selected, extractedSymbols, plan.Tests)
+	}
+	plan.Selected = selected
+
+	return plan, nil
+}
+
+// invalidate drops cached data affected by changes to files.
+func (p *planner) invalidate(files []string) {
+	changedPackages, _ := mapFilesToPackages(files)
+	changedPackages = deduplicate(changedPackages)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cache.Invalidate(changedPackages)
+	for _, pkg := range changedPackages {
+		delete(p.tests, pkg)
+	}
+	for _, file := range files {
+		if mod := p.ws.ModuleForFile(file); mod != nil {
+			delete(p.graphs, mod.Dir)
+		}
+	}
+}
+
+// importGraph returns the imports, including test imports, of every package
+// in mod.
+func (p *planner) importGraph(mod *modules.Module) (map[string][]string, error) {
+	if graph, ok := p.graphs[mod.Dir]; ok {
+		return graph, nil
+	}
+
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}{{range .Imports}} {{.}}{{end}}{{range .TestImports}} {{.}}{{end}}", "./...")
+	cmd.Dir = mod.Dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed in %s: %w", mod.Dir, err)
+	}
+
+	graph := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		graph[fields[0]] = fields[1:]
+	}
+
+	p.graphs[mod.Dir] = graph
+	return graph, nil
+}
+
+// findDependentPackages returns packages importing any changed package, along
+// with the importers of every package in the searched modules, so import
+// chains can be followed beyond direct dependents. Every module that can see a
+// changed package's module is searched, and each is listed from its own root
+// so sibling modules are not missed.
+func (p *planner) findDependentPackages(changedPackages []string) ([]string, map[string][]string, error) {
+	if len(changedPackages) == 0 {
+		return nil, nil, nil
+	}
+
+	changedSet := make(map[string]struct{})
+	for _, pkg := range changedPackages {
+		changedSet[pkg] = struct{}{}
+	}
+
+	var candidates []*modules.Module
+	seenModules := make(map[*modules.Module]bool)
+	for _, pkg := range changedPackages {
+		mod := p.ws.ModuleForPackage(pkg)
+		if mod == nil {
+			continue
+		}
+		for _, dep := range p.ws.Dependents(mod) {
+			if !seenModules[dep] {
+				seenModules[dep] = true
+				candidates = append(candidates, dep)
+			}
+		}
+	}
+
+	var dependentPackages []string
+	importers := make(map[string][]string)
+	var lastErr error
+
+	for _, mod := range candidates {
+		graph, err := p.importGraph(mod)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		pkgs := make([]string, 0, len(graph))
+		for pkg := range graph {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+
+		for _, pkg := range pkgs {
+			imports := graph[pkg]
+			for _, imp := range imports {
+				if imp != pkg {
+					importers[imp] = append(importers[imp], pkg)
+				}
+			}
+			if _, ok := changedSet[pkg]; ok {
+				continue
+			}
+
+			isDependent := false
+			for _, imp := range imports {
+				if _, ok := changedSet[imp]; ok {
+					isDependent = true
+				}
+			}
+			if isDependent {
+				dependentPackages = append(dependentPackages, pkg)
+			}
+		}
+	}
+
+	if len(dependentPackages) == 0 && lastErr != nil {
+		return nil, nil, lastErr
+	}
+
+	for pkg, pkgImporters := range importers {
+		importers[pkg] = deduplicate(pkgImporters)
+	}
+
+	return dependentPackages, importers, nil
+}
+
+// discoverTests groups packages by owning module and discovers tests from each
+// module root, reusing the inventory of packages seen before.
+func (p *planner) discoverTests(packages []string) ([]tests.Test, error) {
+	byDir := make(map[string][]string)
+	var dirs []string
+	for _, pkg := range packages {
+		if _, ok := p.tests[pkg]; ok {
+			continue
+		}
+		dir := moduleDir(p.ws, pkg)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], pkg)
+	}
+
+	for _, dir := range dirs {
+		found, err := tests.DiscoverFromPackagesInDir(dir, byDir[dir])
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range byDir[dir] {
+			p.tests[pkg] = []tests.Test{}
+		}
+		for _, test := range found {
+			p.tests[test.Package] = append(p.tests[test.Package], test)
+		}
+	}
+
+	var discovered []tests.Test
+	for _, pkg := range packages {
+		discovered = append(discovered, p.tests[pkg]...)
+	}
+
+	return discovered, nil
+}
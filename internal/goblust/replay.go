@@ -0,0 +1,208 @@
+package goblust
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/selector"
+)
+
+type ReplayOptions struct {
+	From string
+	To   string
+	// Strategies to compare; empty means every registered strategy.
+	Strategies []string
+	// Format is table, csv or json.
+	Format string
+}
+
+type replayCommit struct {
+	Commit     string           `json:"commit"`
+	Subject    string           `json:"subject"`
+	Skipped    string           `json:"skipped,omitempty"`
+	Files      int              `json:"files_changed"`
+	GoFiles    int              `json:"go_files_changed"`
+	Symbols    int              `json:"symbols_changed"`
+	Strategies []replayStrategy `json:"strategies,omitempty"`
+}
+
+type replayStrategy struct {
+	Strategy string `json:"strategy"`
+	Selected int    `json:"selected"`
+	// AnalysisMillis is the time to plan from a cold planner, so strategies
+	// are timed on equal terms.
+	AnalysisMillis int64  `json:"analysis_ms"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Replay plans every commit of a range with each strategy, without running
+// tests, and writes the comparison to stdout. Progress goes to stderr so the
+// CSV and JSON output can be piped.
+func Replay(opts Options, replayOpts ReplayOptions) error {
+	strategies := replayOpts.Strategies
+	if len(strategies) == 0 {
+		strategies = selector.Names()
+	}
+	for _, name := range strategies {
+		if _, err := selector.GetStrategy(name); err != nil {
+			return err
+		}
+	}
+
+	var write func(io.Writer, []string, []replayCommit) error
+	switch replayOpts.Format {
+	case "table", "":
+		write = writeReplayTable
+	case "csv":
+		write = writeReplayCSV
+	case "json":
+		write = func(w io.Writer, _ []string, commits []replayCommit) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(commits)
+		}
+	default:
+		return fmt.Errorf("unknown replay format: %s", replayOpts.Format)
+	}
+
+	commits, err := commitRange(replayOpts.From, replayOpts.To)
+	if err != nil {
+		return err
+	}
+
+	origDir, subdir, err := repoSubdir()
+	if err != nil {
+		return err
+	}
+
+	var results []replayCommit
+	for _, commit := range commits {
+		fmt.Fprintf(os.Stderr, "Replaying %s...\n", shortCommit(commit))
+
+		result, err := replayCommitPlans(opts, commit, subdir, strategies)
+		if chdirErr := os.Chdir(origDir); chdirErr != nil {
+			return chdirErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replay %s: %w", shortCommit(commit), err)
+		}
+		results = append(results, result)
+	}
+
+	return write(os.Stdout, strategies, results)
+}
+
+func replayCommitPlans(opts Options, commit, subdir string, strategies []string) (replayCommit, error) {
+	result := replayCommit{Commit: commit}
+	result.Subject, _ = gitOutput("log", "-1", "--format=%s", commit)
+
+	if !hasParent(commit) {
+		result.Skipped = "root commit"
+		return result, nil
+	}
+
+	cleanup, err := enterCommitWorktree(commit, subdir)
+	if err != nil {
+		return result, err
+	}
+	defer cleanup()
+
+	changedFiles, changes, err := commitChanges(opts, commit)
+	if err != nil {
+		return result, err
+	}
+	goFiles, extractedSymbols := changes.GoFiles, changes.Symbols
+	result.Files = len(changedFiles)
+	result.GoFiles = len(goFiles)
+	result.Symbols = len(extractedSymbols)
+	if len(goFiles) == 0 {
+		result.Skipped = "no Go changes"
+		return result, nil
+	}
+
+	for _, name := range strategies {
+		start := time.Now()
+		entry := replayStrategy{Strategy: name}
+
+		p, err := newPlanner()
+		if err == nil {
+			var plan *Plan
+			plan, err = p.plan(name, goFiles, extractedSymbols)
+			if err == nil {
+				entry.Selected = len(plan.Selected)
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		entry.AnalysisMillis = time.Since(start).Milliseconds()
+		result.Strategies = append(result.Strategies, entry)
+	}
+
+	return result, nil
+}
+
+func writeReplayTable(w io.Writer, strategies []string, commits []replayCommit) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-12s  %5s  %3s  %7s", "commit", "files", "go", "symbols"))
+	for _, name := range strategies {
+		sb.WriteString(fmt.Sprintf("  %20s", name))
+	}
+	sb.WriteString("\n")
+
+	for _, c := range commits {
+		sb.WriteString(fmt.Sprintf("%-12s  %5d  %3d  %7d", shortCommit(c.Commit), c.Files, c.GoFiles, c.Symbols))
+		if c.Skipped != "" {
+			sb.WriteString(fmt.Sprintf("  skipped: %s\n", c.Skipped))
+			continue
+		}
+		for _, s := range c.Strategies {
+			cell := "error"
+			if s.Error == "" {
+				cell = fmt.Sprintf("%d tests %dms", s.Selected, s.AnalysisMillis)
+			}
+			sb.WriteString(fmt.Sprintf("  %20s", cell))
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeReplayCSV(w io.Writer, strategies []string, commits []replayCommit) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"commit", "subject", "files_changed", "go_files_changed", "symbols_changed"}
+	for _, name := range strategies {
+		header = append(header, name+"_selected", name+"_analysis_ms")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range commits {
+		row := []string{c.Commit, c.Subject, strconv.Itoa(c.Files), strconv.Itoa(c.GoFiles), strconv.Itoa(c.Symbols)}
+		for i := range strategies {
+			if i >= len(c.Strategies) || c.Strategies[i].Error != "" {
+				row = append(row, "", "")
+				continue
+			}
+			s := c.Strategies[i]
+			row = append(row, strconv.Itoa(s.Selected), strconv.FormatInt(s.AnalysisMillis, 10))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
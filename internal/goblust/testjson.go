@@ -0,0 +1,116 @@
+package goblust
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/selector"
+)
+
+// testEvent is a line of `go test -json` output.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+type testResult struct {
+	ID       selector.TestID
+	Passed   bool
+	Duration time.Duration
+}
+
+// runPackageTests runs the named tests of pkg from dir with -json, printing
+// output the way plain `go test` would: package summaries always, test output
+// only for failing tests.
+func runPackageTests(dir, pkg string, testNames []string, out io.Writer) ([]testResult, error) {
+	testPattern := "^(" + strings.Join(testNames, "|") + ")$"
+	return runTestsJSON(dir, []string{pkg, "-run", testPattern}, out)
+}
+
+// runTestsJSON runs `go test -json` with args from dir and collects the
+// results of top-level tests.
+func runTestsJSON(dir string, args []string, out io.Writer) ([]testResult, error) {
+	cmd := exec.Command("go", append([]string{"test", "-json"}, args...)...)
+	cmd.Dir = dir
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	results, parseErr := parseTestEvents(stdout, out)
+	waitErr := cmd.Wait()
+
+	if parseErr != nil {
+		return results, parseErr
+	}
+	return results, waitErr
+}
+
+func parseTestEvents(r io.Reader, out io.Writer) ([]testResult, error) {
+	var results []testResult
+	testOutput := make(map[string][]string)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		var ev testEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			// Build failures are reported as plain text.
+			fmt.Fprintln(out, string(line))
+			continue
+		}
+
+		// Subtests are reported as part of their top-level test.
+		topLevel, _, _ := strings.Cut(ev.Test, "/")
+		key := ev.Package + "::" + topLevel
+
+		switch ev.Action {
+		case "output":
+			if ev.Test == "" {
+				if ev.Output != "PASS\n" {
+					fmt.Fprint(out, ev.Output)
+				}
+				continue
+			}
+			testOutput[key] = append(testOutput[key], ev.Output)
+
+		case "pass", "fail":
+			if ev.Test == "" || ev.Test != topLevel {
+				continue
+			}
+			passed := ev.Action == "pass"
+			if !passed {
+				fmt.Fprint(out, strings.Join(testOutput[key], ""))
+			}
+			delete(testOutput, key)
+
+			results = append(results, testResult{
+				ID:       selector.TestID{Package: ev.Package, TestName: ev.Test},
+				Passed:   passed,
+				Duration: time.Duration(ev.Elapsed * float64(time.Second)),
+			})
+
+		case "skip":
+			delete(testOutput, key)
+		}
+	}
+
+	return results, scanner.Err()
+}
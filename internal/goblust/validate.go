@@ -0,0 +1,359 @@
+package goblust
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/modules"
+	"jombG/goblast/internal/selector"
+)
+
+// ReferenceFull makes validation compare against every test of every module.
+const ReferenceFull = "full"
+
+type ValidateOptions struct {
+	// From and To bound the commit range, as in `git rev-list From..To`.
+	From string
+	To   string
+	// Reference is ReferenceFull or the name of a strategy whose selection is
+	// treated as ground truth, typically "conservative".
+	Reference string
+}
+
+type commitValidation struct {
+	Commit        string
+	Skipped       string
+	Selected      int
+	Reference     int
+	Failing       int
+	Missed        []selector.TestID
+	SelectedTime  time.Duration
+	ReferenceTime time.Duration
+}
+
+// Validate checks out each commit of the range in a temporary worktree,
+// selects tests for the commit's changes with opts.Strategy and with the
+// reference, runs both selections once, and reports reference failures the
+// strategy would have skipped that the commit introduced. Times are the summed
+// test durations of each selection. Validate fails when any failure was missed.
+func Validate(opts Options, validateOpts ValidateOptions) error {
+	if validateOpts.Reference == "" {
+		validateOpts.Reference = ReferenceFull
+	}
+	opts.NoDaemon = true
+
+	commits, err := commitRange(validateOpts.From, validateOpts.To)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		fmt.Println("No commits in range. Nothing to validate.")
+		return nil
+	}
+
+	origDir, subdir, err := repoSubdir()
+	if err != nil {
+		return err
+	}
+
+	var results []commitValidation
+	for _, commit := range commits {
+		fmt.Printf("Validating %s...\n", shortCommit(commit))
+
+		result, err := validateCommit(opts, validateOpts.Reference, commit, subdir)
+		if chdirErr := os.Chdir(origDir); chdirErr != nil {
+			return chdirErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to validate %s: %w", shortCommit(commit), err)
+		}
+		results = append(results, result)
+	}
+
+	fmt.Println(formatValidation(opts.Strategy, validateOpts.Reference, results))
+
+	missed := 0
+	for _, result := range results {
+		missed += len(result.Missed)
+	}
+	if missed > 0 {
+		return fmt.Errorf("strategy %s missed %d failing tests", opts.Strategy, missed)
+	}
+	return nil
+}
+
+// repoSubdir returns the working directory and its path relative to the root
+// of the git repository.
+func repoSubdir() (string, string, error) {
+	topLevel, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	subdir, err := filepath.Rel(topLevel, dir)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, subdir, nil
+}
+
+// hasParent reports whether commit has a parent to diff against.
+func hasParent(commit string) bool {
+	_, err := gitOutput("rev-parse", "--verify", "--quiet", commit+"^")
+	return err == nil
+}
+
+// enterCommitWorktree checks out commit in a temporary worktree and changes to
+// the directory at subdir inside it. The returned function removes the
+// worktree; the caller restores the working directory.
+func enterCommitWorktree(commit, subdir string) (func(), error) {
+	worktree, err := os.MkdirTemp("", "goblast-worktree-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		exec.Command("git", "worktree", "remove", "--force", worktree).Run()
+		os.RemoveAll(worktree)
+	}
+
+	if _, err := gitOutput("worktree", "add", "--detach", worktree, commit); err != nil {
+		os.RemoveAll(worktree)
+		return nil, err
+	}
+	if err := os.Chdir(filepath.Join(worktree, subdir)); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return cleanup, nil
+}
+
+// commitChanges computes the changes commit made relative to its parent the
+// way run computes changes between two commits. It expects the commit to be
+// checked out.
+func commitChanges(opts Options, commit string) ([]string, *changeSet, error) {
+	opts.Base = commit + "^"
+	opts.Head = commit
+	opts.Compare = "direct"
+	opts.Changes = "committed"
+	opts.DiffFile = ""
+
+	changedFiles, err := collectChangedFiles(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, err := computeChanges(opts, changedFiles, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changedFiles, changes, nil
+}
+
+func validateCommit(opts Options, reference, commit, subdir string) (commitValidation, error) {
+	result := commitValidation{Commit: commit}
+
+	if !hasParent(commit) {
+		result.Skipped = "root commit"
+		return result, nil
+	}
+
+	cleanup, err := enterCommitWorktree(commit, subdir)
+	if err != nil {
+		return result, err
+	}
+	defer cleanup()
+
+	_, changes, err := commitChanges(opts, commit)
+	if err != nil {
+		return result, err
+	}
+	goFiles, extractedSymbols := changes.GoFiles, changes.Symbols
+	if len(goFiles) == 0 {
+		result.Skipped = "no Go changes"
+		return result, nil
+	}
+
+	p, err := newPlanner()
+	if err != nil {
+		return result, err
+	}
+	plan, err := p.plan(opts.Strategy, goFiles, extractedSymbols)
+	if err != nil {
+		return result, err
+	}
+	selected := make(map[selector.TestID]bool)
+	for _, id := range plan.Selected {
+		selected[id] = true
+	}
+	result.Selected = len(selected)
+
+	var testResults []testResult
+	var inReference func(selector.TestID) bool
+
+	if reference == ReferenceFull {
+		testResults = runAllTests(p.ws)
+		inReference = func(selector.TestID) bool { return true }
+	} else {
+		referencePlan, err := p.plan(reference, goFiles, extractedSymbols)
+		if err != nil {
+			return result, err
+		}
+		referenced := make(map[selector.TestID]bool)
+		for _, id := range referencePlan.Selected {
+			referenced[id] = true
+		}
+		inReference = func(id selector.TestID) bool { return referenced[id] }
+
+		union := append(append([]selector.TestID{}, plan.Selected...), referencePlan.Selected...)
+		order, byPackage := groupByPackage(union)
+		for _, pkg := range order {
+			pkgResults, _ := runPackageTests(moduleDir(p.ws, pkg), pkg, deduplicate(byPackage[pkg]), io.Discard)
+			testResults = append(testResults, pkgResults...)
+		}
+	}
+
+	for _, r := range testResults {
+		if selected[r.ID] {
+			result.SelectedTime += r.Duration
+		}
+		if !inReference(r.ID) {
+			continue
+		}
+		result.Reference++
+		result.ReferenceTime += r.Duration
+		if r.Passed {
+			continue
+		}
+		result.Failing++
+		if !selected[r.ID] {
+			result.Missed = append(result.Missed, r.ID)
+		}
+	}
+
+	if len(result.Missed) > 0 {
+		result.Missed, err = failuresIntroducedBy(p.ws, commit, result.Missed)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// failuresIntroducedBy re-runs failing tests on the parent of commit and keeps
+// those that passed there. Tests already failing before the commit could not
+// have been caught by selecting tests for its changes.
+func failuresIntroducedBy(ws *modules.Workspace, commit string, failing []selector.TestID) ([]selector.TestID, error) {
+	if _, err := gitOutput("checkout", "--quiet", "--detach", commit+"^"); err != nil {
+		return nil, err
+	}
+
+	passedBefore := make(map[selector.TestID]bool)
+	order, byPackage := groupByPackage(failing)
+	for _, pkg := range order {
+		results, _ := runPackageTests(moduleDir(ws, pkg), pkg, byPackage[pkg], io.Discard)
+		for _, r := range results {
+			if r.Passed {
+				passedBefore[r.ID] = true
+			}
+		}
+	}
+
+	var introduced []selector.TestID
+	for _, id := range failing {
+		if passedBefore[id] {
+			introduced = append(introduced, id)
+		}
+	}
+	return introduced, nil
+}
+
+// runAllTests runs every test of every module. Failures are part of the
+// results, so the error of each run is not needed.
+func runAllTests(ws *modules.Workspace) []testResult {
+	var results []testResult
+	for _, mod := range ws.Modules {
+		modResults, _ := runTestsJSON(mod.Dir, []string{"./..."}, io.Discard)
+		results = append(results, modResults...)
+	}
+	return results
+}
+
+func commitRange(from, to string) ([]string, error) {
+	output, err := gitOutput("rev-list", "--reverse", from+".."+to)
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func formatValidation(strategy, reference string, results []commitValidation) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Validation (%s vs %s) ===\n\n", strategy, reference))
+	sb.WriteString(fmt.Sprintf("%-12s  %8s  %9s  %7s  %6s  %10s  %10s\n",
+		"commit", "selected", "reference", "failing", "missed", "sel. time", "ref. time"))
+
+	var selected, total, missed int
+	var selectedTime, referenceTime time.Duration
+	for _, r := range results {
+		if r.Skipped != "" {
+			sb.WriteString(fmt.Sprintf("%-12s  skipped: %s\n", shortCommit(r.Commit), r.Skipped))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%-12s  %8d  %9d  %7d  %6d  %10s  %10s\n",
+			shortCommit(r.Commit), r.Selected, r.Reference, r.Failing, len(r.Missed),
+			r.SelectedTime.Round(time.Millisecond), r.ReferenceTime.Round(time.Millisecond)))
+
+		selected += r.Selected
+		total += r.Reference
+		missed += len(r.Missed)
+		selectedTime += r.SelectedTime
+		referenceTime += r.ReferenceTime
+	}
+
+	for _, r := range results {
+		for _, id := range r.Missed {
+			sb.WriteString(fmt.Sprintf("\nMissed in %s: %s.%s", shortCommit(r.Commit), id.Package, id.TestName))
+		}
+	}
+	if missed > 0 {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("\nMissed failures: %d\n", missed))
+	if total > 0 {
+		sb.WriteString(fmt.Sprintf("Selection ratio: %d/%d (%.1f%%)\n", selected, total, 100*float64(selected)/float64(total)))
+	}
+	if referenceTime > 0 {
+		saved := referenceTime - selectedTime
+		sb.WriteString(fmt.Sprintf("Time saved: %s (%.1f%%)\n", saved.Round(time.Millisecond), 100*saved.Seconds()/referenceTime.Seconds()))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,307 @@
+package goblust
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/symbols"
+)
+
+type WatchOptions struct {
+	// Interval is how often the module is polled for changed files.
+	Interval time.Duration
+	// Debounce is how long files must stay unchanged before tests run, so a
+	// burst of saves triggers a single run.
+	Debounce time.Duration
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	// content is only kept for Go files; other files, such as go:generate
+	// inputs, are compared by size and modification time.
+	content []byte
+}
+
+type snapshot map[string]fileState
+
+// Watch polls the module's files and, after each burst of saves, runs the
+// tests affected by the symbols that changed since the previous snapshot.
+// Generated files and go:generate inputs are handled as in run mode. Loaded
+// packages are kept between iterations and invalidated per change.
+func Watch(opts Options, watchOpts WatchOptions) error {
+	if watchOpts.Interval <= 0 {
+		watchOpts.Interval = 500 * time.Millisecond
+	}
+
+	previous, err := takeSnapshot(".", nil)
+	if err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+
+	p, err := newPlanner()
+	if err != nil {
+		return err
+	}
+	p.changedTests = true
+
+	fmt.Printf("Watching %d Go files for changes...\n", len(filterGoFiles(previous.paths())))
+
+	for {
+		time.Sleep(watchOpts.Interval)
+
+		current, err := takeSnapshot(".", previous)
+		if err != nil {
+			return fmt.Errorf("failed to scan files: %w", err)
+		}
+		if len(changedPaths(previous, current)) == 0 {
+			continue
+		}
+
+		current, err = waitForQuiet(current, watchOpts.Debounce)
+		if err != nil {
+			return fmt.Errorf("failed to scan files: %w", err)
+		}
+
+		changed := changedPaths(previous, current)
+		changes := newChangeSet(changed)
+		changedSymbols := symbolsChangedBetween(previous, current, changes.GoFiles)
+		previous = current
+		if err := completeChanges(opts, changes, changedSymbols, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+
+		if opts.DebugFiles {
+			fmt.Println("Changed files:")
+			for _, f := range changed {
+				fmt.Printf("  %s\n", f)
+			}
+			fmt.Println()
+		}
+		if opts.DebugSymbols {
+			fmt.Println(symbols.FormatSymbols(changes.Symbols))
+		}
+		if len(changes.Symbols) == 0 {
+			fmt.Println("No symbols changed. Nothing to test.")
+			continue
+		}
+
+		p.invalidate(changes.GoFiles)
+
+		if err := runPipeline(opts, changes.GoFiles, changes.Symbols, p); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		fmt.Println("Watching for changes...")
+	}
+}
+
+// waitForQuiet re-scans until no file has changed for the debounce period.
+func waitForQuiet(current snapshot, debounce time.Duration) (snapshot, error) {
+	if debounce <= 0 {
+		return current, nil
+	}
+
+	for {
+		time.Sleep(debounce)
+
+		next, err := takeSnapshot(".", current)
+		if err != nil {
+			return nil, err
+		}
+		if len(changedPaths(current, next)) == 0 {
+			return next, nil
+		}
+		current = next
+	}
+}
+
+// takeSnapshot records every file under root. Go file contents are only
+// re-read for files whose size or modification time differ from previous.
+func takeSnapshot(root string, previous snapshot) (snapshot, error) {
+	result := make(snapshot)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if prev, ok := previous[path]; ok && prev.size == info.Size() && prev.modTime.Equal(info.ModTime()) {
+			result[path] = prev
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			result[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		result[path] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			content: content,
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func changedPaths(old, current snapshot) []string {
+	var changed []string
+	for path, state := range current {
+		prev, ok := old[path]
+		switch {
+		case !ok:
+			changed = append(changed, path)
+		case strings.HasSuffix(path, ".go"):
+			if !bytes.Equal(prev.content, state.content) {
+				changed = append(changed, path)
+			}
+		case prev.size != state.size || !prev.modTime.Equal(state.modTime):
+			changed = append(changed, path)
+		}
+	}
+	for path := range old {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+func (s snapshot) paths() []string {
+	paths := make([]string, 0, len(s))
+	for path := range s {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// symbolsChangedBetween returns the symbols of files whose declaration text
+// differs between the two snapshots, including added and removed ones.
+func symbolsChangedBetween(old, current snapshot, files []string) []symbols.Symbol {
+	var changed []symbols.Symbol
+
+	for _, file := range files {
+		oldSymbols := extractFromSnapshot(old, file)
+		newSymbols := extractFromSnapshot(current, file)
+
+		oldText := make(map[string]string)
+		for _, sym := range oldSymbols {
+			oldText[symbolKey(sym)] = declarationText(old[file].content, sym)
+		}
+
+		seen := make(map[string]bool)
+		for _, sym := range newSymbols {
+			key := symbolKey(sym)
+			seen[key] = true
+			if text, ok := oldText[key]; !ok || text != declarationText(current[file].content, sym) {
+				changed = append(changed, sym)
+			}
+		}
+		for _, sym := range oldSymbols {
+			if !seen[symbolKey(sym)] {
+				changed = append(changed, sym)
+			}
+		}
+	}
+
+	return changed
+}
+
+// declarationsChanged compares file as read by readPrevious and read, like
+// symbolsChangedBetween. A file readPrevious cannot read is new, so all of its
+// declarations count as changed.
+func declarationsChanged(file string, readPrevious, read symbols.SourceReader) ([]symbols.Symbol, error) {
+	current, err := read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	old := snapshot{}
+	if previous, err := readPrevious(file); err == nil {
+		// A nil content would make extraction read the working tree.
+		old[file] = fileState{content: append([]byte{}, previous...)}
+	}
+
+	return symbolsChangedBetween(old, snapshot{file: {content: append([]byte{}, current...)}}, []string{file}), nil
+}
+
+// onlyChangedDeclarations drops symbols whose declaration text is the same
+// in the previous revision. Files that cannot be read keep all their symbols.
+func onlyChangedDeclarations(syms []symbols.Symbol, readPrevious, read symbols.SourceReader) []symbols.Symbol {
+	if read == nil {
+		read = os.ReadFile
+	}
+
+	changed := make(map[string]map[string]bool)
+	var result []symbols.Symbol
+	for _, sym := range syms {
+		fileChanges, ok := changed[sym.File]
+		if !ok {
+			declared, err := declarationsChanged(sym.File, readPrevious, read)
+			if err == nil {
+				fileChanges = make(map[string]bool)
+				for _, c := range declared {
+					fileChanges[symbolKey(c)] = true
+				}
+			}
+			changed[sym.File] = fileChanges
+		}
+		if fileChanges == nil || fileChanges[symbolKey(sym)] {
+			result = append(result, sym)
+		}
+	}
+	return result
+}
+
+func extractFromSnapshot(snap snapshot, file string) []symbols.Symbol {
+	state, ok := snap[file]
+	if !ok {
+		return nil
+	}
+
+	extracted, err := symbols.ExtractFromFilesWithReader([]string{file}, func(string) ([]byte, error) {
+		return state.content, nil
+	})
+	if err != nil {
+		return nil
+	}
+	return extracted
+}
+
+func symbolKey(sym symbols.Symbol) string {
+	return fmt.Sprintf("%s::%s::%s::%s", sym.Package, sym.Kind, sym.Receiver, sym.Name)
+}
+
+func declarationText(content []byte, sym symbols.Symbol) string {
+	lines := strings.Split(string(content), "\n")
+	if sym.Line < 1 || sym.EndLine > len(lines) || sym.Line > sym.EndLine {
+		return ""
+	}
+	return strings.Join(lines[sym.Line-1:sym.EndLine], "\n")
+}
@@ -0,0 +1,155 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"jombG/goblast/internal/selector"
+)
+
+const DefaultPath = ".goblast/history.json"
+
+// durationWeight is the weight of the latest run in the moving average, so a
+// single slow run on a busy machine does not dominate the estimate.
+const durationWeight = 0.3
+
+type TestRecord struct {
+	Package         string  `json:"package"`
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Runs            int     `json:"runs"`
+	Failures        int     `json:"failures,omitempty"`
+	LastFailure     string  `json:"last_failure,omitempty"`
+	Flakes          int     `json:"flakes,omitempty"`
+	LastFlake       string  `json:"last_flake,omitempty"`
+	// ConsecutivePasses counts runs since the last failure or flake.
+	ConsecutivePasses int `json:"consecutive_passes,omitempty"`
+}
+
+// Store keeps per-test history across goblast runs in a JSON file.
+type Store struct {
+	path  string
+	Tests map[string]*TestRecord `json:"tests"`
+}
+
+// Load reads the store at path. A missing file yields an empty store.
+func Load(path string) (*Store, error) {
+	store := &Store{
+		path:  path,
+		Tests: make(map[string]*TestRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if store.Tests == nil {
+		store.Tests = make(map[string]*TestRecord)
+	}
+
+	return store, nil
+}
+
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, append(data, '\n'), 0o644)
+}
+
+func (s *Store) record(id selector.TestID) *TestRecord {
+	key := key(id)
+	rec, ok := s.Tests[key]
+	if !ok {
+		rec = &TestRecord{Package: id.Package, Name: id.TestName}
+		s.Tests[key] = rec
+	}
+	return rec
+}
+
+func (s *Store) RecordDuration(id selector.TestID, d time.Duration) {
+	rec := s.record(id)
+	if rec.Runs == 0 {
+		rec.DurationSeconds = d.Seconds()
+	} else {
+		rec.DurationSeconds = durationWeight*d.Seconds() + (1-durationWeight)*rec.DurationSeconds
+	}
+	rec.Runs++
+}
+
+func (s *Store) RecordPass(id selector.TestID) {
+	s.record(id).ConsecutivePasses++
+}
+
+// RecordFailure records a failure that persisted through every retry.
+func (s *Store) RecordFailure(id selector.TestID, at time.Time) {
+	rec := s.record(id)
+	rec.Failures++
+	rec.LastFailure = at.UTC().Format(time.RFC3339)
+	rec.ConsecutivePasses = 0
+}
+
+// RecordFlake records a test that failed and then passed on retry.
+func (s *Store) RecordFlake(id selector.TestID, at time.Time) {
+	rec := s.record(id)
+	rec.Flakes++
+	rec.LastFlake = at.UTC().Format(time.RFC3339)
+	rec.ConsecutivePasses = 0
+}
+
+func (s *Store) ConsecutivePasses(id selector.TestID) int {
+	if rec, ok := s.Tests[key(id)]; ok {
+		return rec.ConsecutivePasses
+	}
+	return 0
+}
+
+// FailedSince returns the tests whose last failure was at or after since.
+func (s *Store) FailedSince(since time.Time) map[selector.TestID]bool {
+	result := make(map[selector.TestID]bool)
+	for _, rec := range s.Tests {
+		if rec.LastFailure == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, rec.LastFailure)
+		if err != nil || at.Before(since) {
+			continue
+		}
+		result[selector.TestID{Package: rec.Package, TestName: rec.Name}] = true
+	}
+	return result
+}
+
+// Durations returns the expected duration of every test with history.
+func (s *Store) Durations() map[selector.TestID]time.Duration {
+	result := make(map[selector.TestID]time.Duration)
+	for _, rec := range s.Tests {
+		if rec.Runs == 0 {
+			continue
+		}
+		id := selector.TestID{Package: rec.Package, TestName: rec.Name}
+		result[id] = time.Duration(rec.DurationSeconds * float64(time.Second))
+	}
+	return result
+}
+
+func key(id selector.TestID) string {
+	return id.Package + "::" + id.TestName
+}
@@ -0,0 +1,251 @@
+package modules
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+type Module struct {
+	Path     string
+	Dir      string
+	Requires []string
+	// LocalReplaces maps replaced module paths to the absolute directories
+	// they point at, for replace directives with a local filesystem target.
+	LocalReplaces map[string]string
+}
+
+type Workspace struct {
+	Root     string
+	WorkFile string
+	Modules  []*Module
+}
+
+// Discover finds the modules goblast should consider. A go.work file wins when
+// present; otherwise every go.mod below root is treated as a separate module.
+func Discover(root string) (*Workspace, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &Workspace{Root: absRoot}
+
+	workFile := findWorkFile(absRoot)
+	if workFile != "" {
+		ws.WorkFile = workFile
+		dirs, err := parseWorkFile(workFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			mod, err := loadModule(dir)
+			if err != nil {
+				return nil, err
+			}
+			ws.Modules = append(ws.Modules, mod)
+		}
+	} else {
+		dirs, err := findModuleDirs(absRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			mod, err := loadModule(dir)
+			if err != nil {
+				return nil, err
+			}
+			ws.Modules = append(ws.Modules, mod)
+		}
+	}
+
+	if len(ws.Modules) == 0 {
+		if gomod := goEnv(absRoot, "GOMOD"); gomod != "" && gomod != os.DevNull {
+			mod, err := loadModule(filepath.Dir(gomod))
+			if err != nil {
+				return nil, err
+			}
+			ws.Modules = append(ws.Modules, mod)
+		}
+	}
+
+	// Longest directory first, so nested modules win in ModuleForFile.
+	sort.Slice(ws.Modules, func(i, j int) bool {
+		return len(ws.Modules[i].Dir) > len(ws.Modules[j].Dir)
+	})
+
+	return ws, nil
+}
+
+func findWorkFile(root string) string {
+	gowork := goEnv(root, "GOWORK")
+	if gowork == "off" {
+		return ""
+	}
+	return gowork
+}
+
+func goEnv(dir, name string) string {
+	cmd := exec.Command("go", "env", name)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func parseWorkFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	work, err := modfile.ParseWork(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	base := filepath.Dir(path)
+	var dirs []string
+	for _, use := range work.Use {
+		dirs = append(dirs, resolveDir(base, use.Path))
+	}
+	return dirs, nil
+}
+
+func findModuleDirs(root string) ([]string, error) {
+	var dirs []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+func loadModule(dir string) (*Module, error) {
+	path := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Module == nil {
+		return nil, fmt.Errorf("%s has no module directive", path)
+	}
+
+	mod := &Module{
+		Path:          file.Module.Mod.Path,
+		Dir:           dir,
+		LocalReplaces: make(map[string]string),
+	}
+	for _, req := range file.Require {
+		mod.Requires = append(mod.Requires, req.Mod.Path)
+	}
+	for _, rep := range file.Replace {
+		if modfile.IsDirectoryPath(rep.New.Path) {
+			mod.LocalReplaces[rep.Old.Path] = resolveDir(dir, rep.New.Path)
+		}
+	}
+
+	return mod, nil
+}
+
+func resolveDir(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, filepath.FromSlash(path))
+}
+
+// ModuleForFile returns the module owning file, which may be relative to the
+// workspace root.
+func (w *Workspace) ModuleForFile(file string) *Module {
+	abs := file
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(w.Root, file)
+	}
+	for _, mod := range w.Modules {
+		if abs == mod.Dir || strings.HasPrefix(abs, mod.Dir+string(filepath.Separator)) {
+			return mod
+		}
+	}
+	return nil
+}
+
+// ModuleForPackage returns the module whose path is the longest prefix of the
+// given import path.
+func (w *Workspace) ModuleForPackage(pkg string) *Module {
+	var best *Module
+	for _, mod := range w.Modules {
+		if pkg == mod.Path || strings.HasPrefix(pkg, mod.Path+"/") {
+			if best == nil || len(mod.Path) > len(best.Path) {
+				best = mod
+			}
+		}
+	}
+	return best
+}
+
+// Dependents returns the modules that can import packages from mod: mod
+// itself, every module requiring it or replacing a dependency with its
+// directory, and in go.work mode every workspace module.
+func (w *Workspace) Dependents(mod *Module) []*Module {
+	var result []*Module
+	for _, other := range w.Modules {
+		if other == mod || w.WorkFile != "" || other.dependsOn(mod) {
+			result = append(result, other)
+		}
+	}
+	return result
+}
+
+func (m *Module) dependsOn(target *Module) bool {
+	for _, req := range m.Requires {
+		if req == target.Path {
+			return true
+		}
+	}
+	for old, dir := range m.LocalReplaces {
+		if old == target.Path || dir == target.Dir {
+			return true
+		}
+	}
+	return false
+}
+
+// RelDir returns the module directory relative to the workspace root.
+func (w *Workspace) RelDir(mod *Module) string {
+	rel, err := filepath.Rel(w.Root, mod.Dir)
+	if err != nil {
+		return mod.Dir
+	}
+	return rel
+}
@@ -0,0 +1,206 @@
+package mutation
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// Mutant is a copy of a source file with a single small change applied inside
+// one function.
+type Mutant struct {
+	Symbol      symbols.Symbol
+	File        string
+	Line        int
+	Description string
+	Source      []byte
+}
+
+var flipped = map[token.Token]token.Token{
+	token.EQL: token.NEQ,
+	token.NEQ: token.EQL,
+	token.LSS: token.GEQ,
+	token.GEQ: token.LSS,
+	token.GTR: token.LEQ,
+	token.LEQ: token.GTR,
+}
+
+// site is a mutation point. apply changes the AST in place and returns a
+// function restoring it.
+type site struct {
+	pos         token.Pos
+	description string
+	apply       func() func()
+}
+
+// Generate returns the mutants of the function or method sym declared in src:
+// each comparison flipped, each boolean return negated, and each return
+// replaced with zero values.
+func Generate(sym symbols.Symbol, src []byte) ([]Mutant, error) {
+	if sym.Kind != "func" && sym.Kind != "method" {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, sym.File, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	decl := findFunc(file, fset, sym)
+	if decl == nil || decl.Body == nil {
+		return nil, nil
+	}
+
+	var mutants []Mutant
+	for _, s := range collectSites(decl) {
+		undo := s.apply()
+		var buf bytes.Buffer
+		err := format.Node(&buf, fset, file)
+		undo()
+		if err != nil {
+			continue
+		}
+
+		mutants = append(mutants, Mutant{
+			Symbol:      sym,
+			File:        sym.File,
+			Line:        fset.Position(s.pos).Line,
+			Description: s.description,
+			Source:      buf.Bytes(),
+		})
+	}
+
+	return mutants, nil
+}
+
+func findFunc(file *ast.File, fset *token.FileSet, sym symbols.Symbol) *ast.FuncDecl {
+	for _, d := range file.Decls {
+		decl, ok := d.(*ast.FuncDecl)
+		if !ok || decl.Name.Name != sym.Name {
+			continue
+		}
+		if fset.Position(decl.Pos()).Line == sym.Line {
+			return decl
+		}
+	}
+	return nil
+}
+
+func collectSites(decl *ast.FuncDecl) []site {
+	var sites []site
+
+	results := resultTypes(decl)
+	returnsBool := len(results) == 1 && isIdent(results[0], "bool")
+
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncLit:
+			// Returns inside closures belong to a different signature.
+			return false
+
+		case *ast.BinaryExpr:
+			if to, ok := flipped[node.Op]; ok {
+				from := node.Op
+				sites = append(sites, site{
+					pos:         node.OpPos,
+					description: fmt.Sprintf("flip %s to %s", from, to),
+					apply: func() func() {
+						node.Op = to
+						return func() { node.Op = from }
+					},
+				})
+			}
+
+		case *ast.ReturnStmt:
+			if len(node.Results) == 0 || len(node.Results) != len(results) {
+				return true
+			}
+			original := node.Results
+
+			if returnsBool {
+				sites = append(sites, site{
+					pos:         node.Pos(),
+					description: "negate boolean return",
+					apply: func() func() {
+						node.Results = []ast.Expr{&ast.UnaryExpr{Op: token.NOT, X: &ast.ParenExpr{X: original[0]}}}
+						return func() { node.Results = original }
+					},
+				})
+			}
+
+			sites = append(sites, site{
+				pos:         node.Pos(),
+				description: "return zero values",
+				apply: func() func() {
+					zeros := make([]ast.Expr, len(results))
+					for i, typ := range results {
+						zeros[i] = zeroValue(typ)
+					}
+					node.Results = zeros
+					return func() { node.Results = original }
+				},
+			})
+		}
+		return true
+	})
+
+	return sites
+}
+
+// resultTypes returns one type expression per result of decl.
+func resultTypes(decl *ast.FuncDecl) []ast.Expr {
+	if decl.Type.Results == nil {
+		return nil
+	}
+
+	var types []ast.Expr
+	for _, field := range decl.Type.Results.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// zeroValue returns an expression for the zero value of typ. `*new(T)` is
+// valid for any type; common types get their literal form.
+func zeroValue(typ ast.Expr) ast.Expr {
+	if ident, ok := typ.(*ast.Ident); ok {
+		switch ident.Name {
+		case "bool":
+			return ast.NewIdent("false")
+		case "string":
+			return &ast.BasicLit{Kind: token.STRING, Value: `""`}
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"float32", "float64", "byte", "rune":
+			return &ast.BasicLit{Kind: token.INT, Value: "0"}
+		case "error", "any":
+			return ast.NewIdent("nil")
+		}
+	}
+
+	switch typ.(type) {
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		if array, ok := typ.(*ast.ArrayType); !ok || array.Len == nil {
+			return ast.NewIdent("nil")
+		}
+	}
+
+	return &ast.StarExpr{X: &ast.CallExpr{Fun: ast.NewIdent("new"), Args: []ast.Expr{typ}}}
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
@@ -0,0 +1,92 @@
+package quarantine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"jombG/goblast/internal/selector"
+)
+
+const DefaultPath = "goblast.quarantine.json"
+
+const dateLayout = "2006-01-02"
+
+// Entry is a known-flaky test whose failures should not fail the run until
+// Expires. Owner is responsible for fixing or removing it.
+type Entry struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+	Owner   string `json:"owner"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (e Entry) ID() selector.TestID {
+	return selector.TestID{Package: e.Package, TestName: e.Test}
+}
+
+// Expired reports whether the entry's expiry date has passed. The entry is
+// still valid on the expiry date itself.
+func (e Entry) Expired(now time.Time) bool {
+	expires, err := time.Parse(dateLayout, e.Expires)
+	if err != nil {
+		return true
+	}
+	return now.After(expires.Add(24 * time.Hour))
+}
+
+type List struct {
+	Entries []Entry `json:"tests"`
+}
+
+// Load reads the quarantine list at path. A missing file yields an empty list.
+func Load(path string) (*List, error) {
+	list := &List{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, entry := range list.Entries {
+		if entry.Package == "" || entry.Test == "" {
+			return nil, fmt.Errorf("%s: entries need a package and a test", path)
+		}
+		if _, err := time.Parse(dateLayout, entry.Expires); err != nil {
+			return nil, fmt.Errorf("%s: invalid expiry %q for %s.%s, want YYYY-MM-DD", path, entry.Expires, entry.Package, entry.Test)
+		}
+	}
+
+	return list, nil
+}
+
+// Active returns the entries that have not expired, keyed by test.
+func (l *List) Active(now time.Time) map[selector.TestID]Entry {
+	active := make(map[selector.TestID]Entry)
+	for _, entry := range l.Entries {
+		if !entry.Expired(now) {
+			active[entry.ID()] = entry
+		}
+	}
+	return active
+}
+
+func (l *List) Expired(now time.Time) []Entry {
+	var expired []Entry
+	for _, entry := range l.Entries {
+		if entry.Expired(now) {
+			expired = append(expired, entry)
+		}
+	}
+	return expired
+}
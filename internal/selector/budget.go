@@ -0,0 +1,148 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/usage"
+)
+
+// Impact ranks, from the most to the least likely to catch a regression.
+const (
+	ImpactDirect = iota
+	ImpactHelper
+	ImpactImplementation
+	ImpactInterface
+	ImpactFallback
+	ImpactDependent
+)
+
+var impactByVia = map[string]int{
+	usage.ViaDirect:         ImpactDirect,
+	usage.ViaHelper:         ImpactHelper,
+	usage.ViaImplementation: ImpactImplementation,
+	usage.ViaInterface:      ImpactInterface,
+}
+
+// RankByImpact ranks each selected test by how it is linked to the change:
+// tests using a changed symbol first, then tests of changed packages selected
+// by fallback, then tests of dependent packages.
+func RankByImpact(selected []TestID, changedSymbols []symbols.Symbol, usages []usage.Usage) map[TestID]int {
+	usageRank := make(map[TestID]int)
+	for _, u := range usages {
+		pkg := u.TestPackage
+		if pkg == "" {
+			continue
+		}
+		id := TestID{Package: pkg, TestName: u.TestName}
+		rank := impactByVia[u.Via]
+		if current, ok := usageRank[id]; !ok || rank < current {
+			usageRank[id] = rank
+		}
+	}
+
+	changedPackages := make(map[string]bool)
+	for _, sym := range changedSymbols {
+		changedPackages[sym.Package] = true
+	}
+
+	ranks := make(map[TestID]int)
+	for _, id := range selected {
+		switch rank, ok := usageRank[id]; {
+		case ok:
+			ranks[id] = rank
+		case changedPackages[id.Package]:
+			ranks[id] = ImpactFallback
+		default:
+			ranks[id] = ImpactDependent
+		}
+	}
+
+	return ranks
+}
+
+// FitBudget keeps the highest-impact tests whose expected durations fit in
+// budget. Within a rank shorter tests go first, so more of them fit. Tests
+// without history are assumed to take the median recorded duration.
+func FitBudget(selected []TestID, ranks map[TestID]int, durations map[TestID]time.Duration, budget time.Duration) ([]TestID, []TestID) {
+	fallback := medianDuration(durations)
+	expected := func(id TestID) time.Duration {
+		if d, ok := durations[id]; ok {
+			return d
+		}
+		return fallback
+	}
+
+	ordered := make([]TestID, len(selected))
+	copy(ordered, selected)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := ranks[ordered[i]], ranks[ordered[j]]
+		if ri != rj {
+			return ri < rj
+		}
+		di, dj := expected(ordered[i]), expected(ordered[j])
+		if di != dj {
+			return di < dj
+		}
+		return testKey(ordered[i]) < testKey(ordered[j])
+	})
+
+	var kept, dropped []TestID
+	var used time.Duration
+	for _, id := range ordered {
+		d := expected(id)
+		if used+d > budget {
+			dropped = append(dropped, id)
+			continue
+		}
+		used += d
+		kept = append(kept, id)
+	}
+
+	return kept, dropped
+}
+
+// ExpectedDuration sums the expected durations of selected tests. Tests
+// without history count as the median recorded duration, and are reported
+// in unknown.
+func ExpectedDuration(selected []TestID, durations map[TestID]time.Duration) (total time.Duration, unknown int) {
+	fallback := medianDuration(durations)
+	for _, id := range selected {
+		d, ok := durations[id]
+		if !ok {
+			d = fallback
+			unknown++
+		}
+		total += d
+	}
+	return total, unknown
+}
+
+// medianDuration returns the median of all recorded durations, or zero when
+// nothing was recorded.
+func medianDuration(durations map[TestID]time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	all := make([]time.Duration, 0, len(durations))
+	for _, d := range durations {
+		all = append(all, d)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	return all[len(all)/2]
+}
+
+func FormatDropped(dropped []TestID, budget time.Duration) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Dropped to fit budget (%s) ===\n\n", budget))
+
+	for _, id := range dropped {
+		sb.WriteString(fmt.Sprintf("  - %s.%s\n", id.Package, id.TestName))
+	}
+
+	sb.WriteString(fmt.Sprintf("\nTotal: %d tests dropped\n", len(dropped)))
+	return sb.String()
+}
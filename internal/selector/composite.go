@@ -0,0 +1,307 @@
+package selector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/tests"
+	"jombG/goblast/internal/usage"
+)
+
+// CompositeNode combines strategies. Exactly one field is set: Strategy names
+// a strategy accepted by GetStrategy, Union selects tests chosen by any child,
+// Intersect selects tests chosen by every child.
+type CompositeNode struct {
+	Strategy  string          `json:"strategy,omitempty"`
+	Union     []CompositeNode `json:"union,omitempty"`
+	Intersect []CompositeNode `json:"intersect,omitempty"`
+}
+
+// CompositeRoute applies Use to tests whose package matches Packages, a
+// path.Match pattern where a trailing "/..." also matches subpackages.
+type CompositeRoute struct {
+	Packages string        `json:"packages"`
+	Use      CompositeNode `json:"use"`
+}
+
+// CompositeConfig is the JSON configuration of a CompositeStrategy. Each test
+// is decided by the first route matching its package, or by Default. Always
+// lists tests that are selected on every run, such as smoke tests.
+type CompositeConfig struct {
+	Routes  []CompositeRoute `json:"routes"`
+	Default CompositeNode    `json:"default"`
+	Always  []TestID         `json:"always"`
+}
+
+// CompositeStrategy routes and combines other strategies. After Select,
+// Sources reports which sub-strategies contributed each selected test.
+type CompositeStrategy struct {
+	name    string
+	config  CompositeConfig
+	sources map[TestID][]string
+}
+
+// SourceAlways is the source reported for tests a composite strategy selects
+// because its configuration lists them under "always".
+const SourceAlways = "always"
+
+func LoadCompositeStrategy(configPath string) (*CompositeStrategy, error) {
+	return loadCompositeStrategy(configPath, make(map[string]bool))
+}
+
+// loadCompositeStrategy loads a configuration, given the configurations
+// already being loaded by enclosing composites, to reject reference cycles.
+func loadCompositeStrategy(configPath string, loading map[string]bool) (*CompositeStrategy, error) {
+	key, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if loading[key] {
+		return nil, fmt.Errorf("composite config %s is part of a reference cycle", configPath)
+	}
+	loading[key] = true
+	defer delete(loading, key)
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config CompositeConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+
+	return newCompositeStrategy("composite:"+configPath, config, loading)
+}
+
+func NewCompositeStrategy(name string, config CompositeConfig) (*CompositeStrategy, error) {
+	return newCompositeStrategy(name, config, make(map[string]bool))
+}
+
+func newCompositeStrategy(name string, config CompositeConfig, loading map[string]bool) (*CompositeStrategy, error) {
+	nodes := []CompositeNode{config.Default}
+	for _, route := range config.Routes {
+		if route.Packages == "" {
+			return nil, fmt.Errorf("composite route without packages pattern")
+		}
+		if _, err := path.Match(strings.TrimSuffix(route.Packages, "/..."), ""); err != nil {
+			return nil, fmt.Errorf("invalid packages pattern %q: %w", route.Packages, err)
+		}
+		nodes = append(nodes, route.Use)
+	}
+	for _, node := range nodes {
+		if err := node.validate(loading); err != nil {
+			return nil, err
+		}
+	}
+
+	return &CompositeStrategy{name: name, config: config}, nil
+}
+
+func (n CompositeNode) validate(loading map[string]bool) error {
+	set := 0
+	if n.Strategy != "" {
+		set++
+		var err error
+		if configPath, ok := strings.CutPrefix(n.Strategy, "composite:"); ok {
+			_, err = loadCompositeStrategy(configPath, loading)
+		} else {
+			_, err = GetStrategy(n.Strategy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(n.Union) > 0 {
+		set++
+	}
+	if len(n.Intersect) > 0 {
+		set++
+	}
+	if set != 1 {
+		return fmt.Errorf("composite node must set exactly one of strategy, union, intersect")
+	}
+
+	for _, child := range append(n.Union, n.Intersect...) {
+		if err := child.validate(loading); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CompositeStrategy) Name() string {
+	return s.name
+}
+
+func (s *CompositeStrategy) Select(changedSymbols []symbols.Symbol, discoveredTests []tests.Test, usages []usage.Usage) []TestID {
+	eval := func(node CompositeNode) map[TestID][]string {
+		return node.evaluate(changedSymbols, discoveredTests, usages)
+	}
+
+	routed := make([]map[TestID][]string, len(s.config.Routes))
+	for i, route := range s.config.Routes {
+		routed[i] = eval(route.Use)
+	}
+	fallback := eval(s.config.Default)
+
+	s.sources = make(map[TestID][]string)
+	var selected []TestID
+
+	consider := func(id TestID, sources []string) {
+		if _, ok := s.sources[id]; !ok {
+			selected = append(selected, id)
+		}
+		s.sources[id] = mergeSources(s.sources[id], sources)
+	}
+
+	candidates := make(map[TestID]bool)
+	for _, result := range append(routed, fallback) {
+		for id := range result {
+			candidates[id] = true
+		}
+	}
+
+	for _, id := range sortedTestIDs(candidates) {
+		route := s.routeFor(id.Package)
+		if route < 0 {
+			if sources, ok := fallback[id]; ok {
+				consider(id, sources)
+			}
+			continue
+		}
+		if sources, ok := routed[route][id]; ok {
+			prefixed := make([]string, len(sources))
+			for i, source := range sources {
+				prefixed[i] = fmt.Sprintf("%s (route %s)", source, s.config.Routes[route].Packages)
+			}
+			consider(id, prefixed)
+		}
+	}
+
+	for _, id := range s.config.Always {
+		consider(id, []string{SourceAlways})
+	}
+
+	return selected
+}
+
+// Sources returns the sub-strategies that contributed each test selected by
+// the last call to Select.
+func (s *CompositeStrategy) Sources() map[TestID][]string {
+	return s.sources
+}
+
+func (s *CompositeStrategy) routeFor(pkg string) int {
+	for i, route := range s.config.Routes {
+		if matchPackagePattern(route.Packages, pkg) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n CompositeNode) evaluate(changedSymbols []symbols.Symbol, discoveredTests []tests.Test, usages []usage.Usage) map[TestID][]string {
+	result := make(map[TestID][]string)
+
+	switch {
+	case n.Strategy != "":
+		strategy, err := GetStrategy(n.Strategy)
+		if err != nil {
+			return result
+		}
+		selected := strategy.Select(changedSymbols, discoveredTests, usages)
+		// Nested composites report their own sources, including SourceAlways.
+		var nested map[TestID][]string
+		if reporter, ok := strategy.(SourceReporter); ok {
+			nested = reporter.Sources()
+		}
+		for _, id := range selected {
+			if sources := nested[id]; len(sources) > 0 {
+				result[id] = sources
+				continue
+			}
+			result[id] = []string{strategy.Name()}
+		}
+
+	case len(n.Union) > 0:
+		for _, child := range n.Union {
+			for id, sources := range child.evaluate(changedSymbols, discoveredTests, usages) {
+				result[id] = mergeSources(result[id], sources)
+			}
+		}
+
+	case len(n.Intersect) > 0:
+		for i, child := range n.Intersect {
+			childResult := child.evaluate(changedSymbols, discoveredTests, usages)
+			if i == 0 {
+				result = childResult
+				continue
+			}
+			for id := range result {
+				sources, ok := childResult[id]
+				if !ok {
+					delete(result, id)
+					continue
+				}
+				result[id] = mergeSources(result[id], sources)
+			}
+		}
+	}
+
+	return result
+}
+
+// matchPackagePattern matches pkg against a path.Match pattern. A trailing
+// "/..." also matches every package below the matched path.
+func matchPackagePattern(pattern, pkg string) bool {
+	base, recursive := strings.CutSuffix(pattern, "/...")
+	if ok, _ := path.Match(base, pkg); ok {
+		return true
+	}
+	if !recursive {
+		return false
+	}
+
+	parts := strings.Split(pkg, "/")
+	for i := len(parts) - 1; i > 0; i-- {
+		if ok, _ := path.Match(base, strings.Join(parts[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeSources(existing, added []string) []string {
+	for _, source := range added {
+		found := false
+		for _, e := range existing {
+			if e == source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, source)
+		}
+	}
+	return existing
+}
+
+func sortedTestIDs(set map[TestID]bool) []TestID {
+	ids := make([]TestID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return testKey(ids[i]) < testKey(ids[j])
+	})
+	return ids
+}
@@ -0,0 +1,163 @@
+package selector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/usage"
+)
+
+// Confidence scores, from tests almost certain to exercise a change to tests
+// that only share a package or import chain with it.
+const (
+	ConfidenceDirect         = 1.0
+	ConfidenceHelper         = 0.8
+	ConfidenceImplementation = 0.7
+	ConfidenceInterface      = 0.6
+	ConfidenceFallback       = 0.5
+	// ConfidenceDependent scores tests of packages importing a changed
+	// package directly; it halves with each further level of imports.
+	ConfidenceDependent = 0.4
+)
+
+var confidenceByVia = map[string]float64{
+	usage.ViaDirect:         ConfidenceDirect,
+	usage.ViaHelper:         ConfidenceHelper,
+	usage.ViaImplementation: ConfidenceImplementation,
+	usage.ViaInterface:      ConfidenceInterface,
+}
+
+// ScoreConfidence scores each selected test by how it is linked to the
+// change. Tests using a changed symbol score by how the use was found; other
+// tests score by the reverse dependency depth of their package, given the
+// importers of each package. Tests unrelated to any changed package score 0.
+func ScoreConfidence(selected []TestID, changedSymbols []symbols.Symbol, usages []usage.Usage, importers map[string][]string) map[TestID]float64 {
+	usageScore := make(map[TestID]float64)
+	for _, u := range usages {
+		pkg := u.TestPackage
+		if pkg == "" {
+			continue
+		}
+		id := TestID{Package: pkg, TestName: u.TestName}
+		if score := confidenceByVia[u.Via]; score > usageScore[id] {
+			usageScore[id] = score
+		}
+	}
+
+	depth := make(map[string]int)
+	var queue []string
+	for _, sym := range changedSymbols {
+		if _, ok := depth[sym.Package]; !ok {
+			depth[sym.Package] = 0
+			queue = append(queue, sym.Package)
+		}
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, importer := range importers[pkg] {
+			if _, ok := depth[importer]; !ok {
+				depth[importer] = depth[pkg] + 1
+				queue = append(queue, importer)
+			}
+		}
+	}
+
+	scores := make(map[TestID]float64)
+	for _, id := range selected {
+		if score, ok := usageScore[id]; ok {
+			scores[id] = score
+			continue
+		}
+		d, ok := depth[id.Package]
+		switch {
+		case !ok:
+			scores[id] = 0
+		case d == 0:
+			scores[id] = ConfidenceFallback
+		default:
+			scores[id] = ConfidenceDependent / float64(int(1)<<(d-1))
+		}
+	}
+
+	return scores
+}
+
+// FilterByConfidence keeps tests scoring at least min, and tests in always
+// regardless of their score, and returns the rest separately.
+func FilterByConfidence(selected []TestID, scores map[TestID]float64, min float64, always map[TestID]bool) ([]TestID, []TestID) {
+	var kept, dropped []TestID
+	for _, id := range selected {
+		if scores[id] < min && !always[id] {
+			dropped = append(dropped, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return kept, dropped
+}
+
+// Prioritize orders selected so the tests most likely to fail run first:
+// tests directly using a changed symbol, then tests that failed recently,
+// then the rest by descending confidence score. Ties go to recent failures.
+func Prioritize(selected []TestID, scores map[TestID]float64, recentlyFailed map[TestID]bool) []TestID {
+	tier := func(id TestID) int {
+		switch {
+		case scores[id] >= ConfidenceDirect:
+			return 0
+		case recentlyFailed[id]:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	ordered := make([]TestID, len(selected))
+	copy(ordered, selected)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ti, tj := tier(ordered[i]), tier(ordered[j])
+		if ti != tj {
+			return ti < tj
+		}
+		si, sj := scores[ordered[i]], scores[ordered[j]]
+		if si != sj {
+			return si > sj
+		}
+		if fi, fj := recentlyFailed[ordered[i]], recentlyFailed[ordered[j]]; fi != fj {
+			return fi
+		}
+		return testKey(ordered[i]) < testKey(ordered[j])
+	})
+	return ordered
+}
+
+// FormatConfidence lists selected tests in execution order with their
+// confidence scores.
+func FormatConfidence(selected []TestID, scores map[TestID]float64, recentlyFailed map[TestID]bool) string {
+	var sb strings.Builder
+	sb.WriteString("\n=== Execution Order ===\n\n")
+
+	for _, id := range selected {
+		note := ""
+		if recentlyFailed[id] {
+			note = " (failed recently)"
+		}
+		sb.WriteString(fmt.Sprintf("  %.2f  %s.%s%s\n", scores[id], id.Package, id.TestName, note))
+	}
+
+	return sb.String()
+}
+
+func FormatBelowConfidence(dropped []TestID, min float64) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n=== Below minimum confidence (%.2f) ===\n\n", min))
+
+	for _, id := range dropped {
+		sb.WriteString(fmt.Sprintf("  - %s.%s\n", id.Package, id.TestName))
+	}
+
+	sb.WriteString(fmt.Sprintf("\nTotal: %d tests skipped\n", len(dropped)))
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package selector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/tests"
+	"jombG/goblast/internal/usage"
+)
+
+// ExternalRequest is written as JSON to the stdin of an external strategy.
+type ExternalRequest struct {
+	ChangedSymbols []symbols.Symbol `json:"changed_symbols"`
+	Tests          []tests.Test     `json:"tests"`
+	Usages         []usage.Usage    `json:"usages"`
+}
+
+// ExternalResponse is read as JSON from the stdout of an external strategy.
+type ExternalResponse struct {
+	Selected []TestID `json:"selected"`
+}
+
+// ExternalStrategy delegates selection to a command. If the command fails or
+// replies with invalid JSON, it falls back to ConservativeStrategy so a broken
+// script never silently skips tests.
+type ExternalStrategy struct {
+	command []string
+}
+
+func NewExternalStrategy(command string) (*ExternalStrategy, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("external strategy needs a command")
+	}
+	return &ExternalStrategy{command: fields}, nil
+}
+
+func (s *ExternalStrategy) Name() string {
+	return "exec:" + strings.Join(s.command, " ")
+}
+
+func (s *ExternalStrategy) Select(changedSymbols []symbols.Symbol, discoveredTests []tests.Test, usages []usage.Usage) []TestID {
+	selected, err := s.run(ExternalRequest{
+		ChangedSymbols: changedSymbols,
+		Tests:          discoveredTests,
+		Usages:         usages,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: external strategy %q failed, falling back to conservative: %v\n", s.Name(), err)
+		return (&ConservativeStrategy{}).Select(changedSymbols, discoveredTests, usages)
+	}
+	return deduplicateTestIDs(selected)
+}
+
+func (s *ExternalStrategy) run(req ExternalRequest) ([]TestID, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	var resp ExternalResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp.Selected, nil
+}
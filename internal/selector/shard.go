@@ -0,0 +1,100 @@
+package selector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// Shard returns the part of selected assigned to shard index out of total.
+// Every shard computes the same partition independently, so CI jobs only need
+// to agree on the inputs. When durations are known, tests are balanced across
+// shards by expected run time, and every shard must be given the same
+// durations; otherwise they are assigned by hash.
+func Shard(selected []TestID, index, total int, durations map[TestID]time.Duration) ([]TestID, error) {
+	if total < 1 {
+		return nil, fmt.Errorf("shard total must be at least 1, got %d", total)
+	}
+	if index < 0 || index >= total {
+		return nil, fmt.Errorf("shard index must be in [0, %d), got %d", total, index)
+	}
+	if total == 1 {
+		return selected, nil
+	}
+
+	if len(durations) == 0 {
+		return shardByHash(selected, index, total), nil
+	}
+	return shardByDuration(selected, index, total, durations), nil
+}
+
+func shardByHash(selected []TestID, index, total int) []TestID {
+	var shard []TestID
+	for _, id := range selected {
+		h := fnv.New32a()
+		h.Write([]byte(testKey(id)))
+		if int(h.Sum32()%uint32(total)) == index {
+			shard = append(shard, id)
+		}
+	}
+	return shard
+}
+
+// shardByDuration assigns the longest tests first, each to the shard with the
+// least total time so far. Tests without history are assumed to take the
+// average known duration.
+func shardByDuration(selected []TestID, index, total int, durations map[TestID]time.Duration) []TestID {
+	var known time.Duration
+	var knownCount int
+	for _, id := range selected {
+		if d, ok := durations[id]; ok {
+			known += d
+			knownCount++
+		}
+	}
+
+	fallback := time.Millisecond
+	if knownCount > 0 {
+		fallback = known / time.Duration(knownCount)
+	}
+
+	ordered := make([]TestID, len(selected))
+	copy(ordered, selected)
+
+	expected := func(id TestID) time.Duration {
+		if d, ok := durations[id]; ok {
+			return d
+		}
+		return fallback
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		di, dj := expected(ordered[i]), expected(ordered[j])
+		if di != dj {
+			return di > dj
+		}
+		return testKey(ordered[i]) < testKey(ordered[j])
+	})
+
+	loads := make([]time.Duration, total)
+	var shard []TestID
+	for _, id := range ordered {
+		target := 0
+		for i := 1; i < total; i++ {
+			if loads[i] < loads[target] {
+				target = i
+			}
+		}
+		loads[target] += expected(id)
+		if target == index {
+			shard = append(shard, id)
+		}
+	}
+
+	return shard
+}
+
+func testKey(id TestID) string {
+	return fmt.Sprintf("%s::%s", id.Package, id.TestName)
+}
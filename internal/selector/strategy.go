@@ -2,6 +2,9 @@ package selector
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	"jombG/goblast/internal/symbols"
 	"jombG/goblast/internal/tests"
@@ -9,8 +12,8 @@ import (
 )
 
 type TestID struct {
-	Package  string
-	TestName string
+	Package  string `json:"package"`
+	TestName string `json:"test"`
 }
 
 type Strategy interface {
@@ -18,17 +21,70 @@ type Strategy interface {
 	Select(changedSymbols []symbols.Symbol, discoveredTests []tests.Test, usages []usage.Usage) []TestID
 }
 
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Strategy)
+)
+
+func init() {
+	Register("symbol-only", func() Strategy { return &SymbolOnlyStrategy{} })
+	Register("package-fallback", func() Strategy { return &PackageFallbackStrategy{} })
+	Register("conservative", func() Strategy { return &ConservativeStrategy{} })
+}
+
+// Register makes a strategy available to GetStrategy under name. It panics if
+// name is already registered, like the standard library's driver registries.
+func Register(name string, factory func() Strategy) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("selector: Register factory is nil for " + name)
+	}
+	if _, dup := registry[name]; dup {
+		panic("selector: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Names returns the registered strategy names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SourceReporter is implemented by strategies that can tell which
+// sub-strategies contributed each selected test.
+type SourceReporter interface {
+	Sources() map[TestID][]string
+}
+
+// GetStrategy returns the registered strategy called name, an external
+// strategy for "exec:<command>", or a composite strategy configured by the
+// JSON file in "composite:<path>".
 func GetStrategy(name string) (Strategy, error) {
-	switch name {
-	case "symbol-only":
-		return &SymbolOnlyStrategy{}, nil
-	case "package-fallback":
-		return &PackageFallbackStrategy{}, nil
-	case "conservative":
-		return &ConservativeStrategy{}, nil
-	default:
+	if command, ok := strings.CutPrefix(name, "exec:"); ok {
+		return NewExternalStrategy(command)
+	}
+	if configPath, ok := strings.CutPrefix(name, "composite:"); ok {
+		return LoadCompositeStrategy(configPath)
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
 		return nil, fmt.Errorf("unknown strategy: %s", name)
 	}
+	return factory(), nil
 }
 
 type SymbolOnlyStrategy struct{}
@@ -42,7 +98,7 @@ func (s *SymbolOnlyStrategy) Select(changedSymbols []symbols.Symbol, discoveredT
 
 	testSet := make(map[string]map[string]bool)
 	for _, u := range usages {
-		pkg := findTestPackage(u.TestName, discoveredTests)
+		pkg := u.TestPackage
 		if pkg == "" {
 			continue
 		}
@@ -76,7 +132,7 @@ func (s *PackageFallbackStrategy) Select(changedSymbols []symbols.Symbol, discov
 	// First, collect tests with direct usages (from ANY package, including dependents)
 	usedTests := make(map[string]map[string]bool) // pkg -> testName -> exists
 	for _, u := range usages {
-		pkg := findTestPackage(u.TestName, discoveredTests)
+		pkg := u.TestPackage
 		if pkg == "" {
 			continue
 		}
@@ -146,13 +202,61 @@ func (s *ConservativeStrategy) Select(changedSymbols []symbols.Symbol, discovere
 	return deduplicateTestIDs(selected)
 }
 
-func findTestPackage(testName string, tests []tests.Test) string {
-	for _, test := range tests {
-		if test.Name == testName {
-			return test.Package
+// WithPackageWide adds every test in packages with a package-wide change
+// (TestMain, init, package-level var initializers, go:generate inputs) and in
+// their importers. It applies on top of any strategy, since such changes are
+// invisible to usage detection.
+func WithPackageWide(selected []TestID, changedSymbols []symbols.Symbol, discoveredTests []tests.Test, importers map[string][]string) []TestID {
+	affected := make(map[string]bool)
+	for _, sym := range changedSymbols {
+		if !sym.PackageWide {
+			continue
 		}
+		affected[sym.Package] = true
+		for _, importer := range importers[sym.Package] {
+			affected[importer] = true
+		}
+	}
+
+	if len(affected) == 0 {
+		return selected
 	}
-	return ""
+
+	for _, test := range discoveredTests {
+		if affected[test.Package] {
+			selected = append(selected, TestID{
+				Package:  test.Package,
+				TestName: test.Name,
+			})
+		}
+	}
+
+	return deduplicateTestIDs(selected)
+}
+
+// WithChangedTests adds tests whose own function changed. Tests never
+// reference themselves, so usage detection cannot select them.
+func WithChangedTests(selected []TestID, changedSymbols []symbols.Symbol, discoveredTests []tests.Test) []TestID {
+	changed := make(map[TestID]bool)
+	for _, sym := range changedSymbols {
+		if sym.Kind == "func" {
+			changed[TestID{Package: sym.Package, TestName: sym.Name}] = true
+		}
+	}
+
+	added := false
+	for _, test := range discoveredTests {
+		id := TestID{Package: test.Package, TestName: test.Name}
+		if changed[id] {
+			selected = append(selected, id)
+			added = true
+		}
+	}
+
+	if !added {
+		return selected
+	}
+	return deduplicateTestIDs(selected)
 }
 
 func deduplicateTestIDs(ids []TestID) []TestID {
@@ -170,7 +274,9 @@ func deduplicateTestIDs(ids []TestID) []TestID {
 	return unique
 }
 
-func FormatSelection(strategy string, selected []TestID) string {
+// FormatSelection lists selected tests by package. When sources is non-nil,
+// each test is annotated with the sub-strategies that selected it.
+func FormatSelection(strategy string, selected []TestID, sources map[TestID][]string) string {
 	if len(selected) == 0 {
 		return fmt.Sprintf("\n=== Test Selection (%s) ===\n\nNo tests selected.\n", strategy)
 	}
@@ -185,6 +291,10 @@ func FormatSelection(strategy string, selected []TestID) string {
 	for pkg, testNames := range byPackage {
 		result += fmt.Sprintf("Package: %s\n", pkg)
 		for _, name := range testNames {
+			if testSources := sources[TestID{Package: pkg, TestName: name}]; len(testSources) > 0 {
+				result += fmt.Sprintf("  - %s [%s]\n", name, strings.Join(testSources, ", "))
+				continue
+			}
 			result += fmt.Sprintf("  - %s\n", name)
 		}
 		result += "\n"
@@ -11,19 +11,48 @@ import (
 )
 
 type Symbol struct {
-	Package  string
-	Name     string
-	Kind     string
-	Receiver string
-	Exported bool
-	Position string
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	// Receiver is the receiver type of a method, or the struct type declaring
+	// a field.
+	Receiver string `json:"receiver"`
+	Exported bool   `json:"exported"`
+	Position string `json:"position"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	EndLine  int    `json:"end_line"`
+	// PackageWide marks changes that affect every test in the package
+	// regardless of references: TestMain, init functions, package-level
+	// variable initializers and inputs of the package's go:generate directives.
+	PackageWide bool `json:"package_wide"`
+	// Generated marks symbols declared in files with the standard
+	// "Code generated ... DO NOT EDIT." comment.
+	Generated bool `json:"generated"`
 }
 
+// SourceReader returns the contents of a file. It allows symbols to be
+// extracted from sources other than the working tree, such as the git index.
+type SourceReader func(path string) ([]byte, error)
+
 func ExtractFromFiles(files []string) ([]Symbol, error) {
+	return ExtractFromFilesWithReader(files, nil)
+}
+
+func ExtractFromFilesWithReader(files []string, read SourceReader) ([]Symbol, error) {
 	var allSymbols []Symbol
 
 	for _, file := range files {
-		symbols, err := extractFromFile(file)
+		var src []byte
+		if read != nil {
+			data, err := read(file)
+			if err != nil {
+				continue
+			}
+			src = data
+		}
+
+		symbols, err := extractFromFile(file, src)
 		if err != nil {
 			continue
 		}
@@ -33,15 +62,22 @@ func ExtractFromFiles(files []string) ([]Symbol, error) {
 	return allSymbols, nil
 }
 
-func extractFromFile(filePath string) ([]Symbol, error) {
+func extractFromFile(filePath string, src []byte) ([]Symbol, error) {
 	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, 0)
+
+	var source any
+	if src != nil {
+		source = src
+	}
+
+	node, err := parser.ParseFile(fset, filePath, source, parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
 
 	var symbols []Symbol
 	packagePath := getPackageImportPath(filePath)
+	generated := ast.IsGenerated(node)
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		switch decl := n.(type) {
@@ -60,6 +96,14 @@ func extractFromFile(filePath string) ([]Symbol, error) {
 						if symbol != nil {
 							symbols = append(symbols, *symbol)
 						}
+						symbols = append(symbols, extractFields(typeSpec, packagePath, fset, filePath)...)
+					}
+				}
+			}
+			if decl.Tok == token.VAR {
+				for _, spec := range decl.Specs {
+					if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+						symbols = append(symbols, extractVars(valueSpec, packagePath, fset, filePath)...)
 					}
 				}
 			}
@@ -68,6 +112,10 @@ func extractFromFile(filePath string) ([]Symbol, error) {
 		return true
 	})
 
+	for i := range symbols {
+		symbols[i].Generated = generated
+	}
+
 	return symbols, nil
 }
 
@@ -77,11 +125,15 @@ func extractFunction(decl *ast.FuncDecl, pkgName string, fset *token.FileSet, fi
 	}
 
 	pos := fset.Position(decl.Pos())
+	end := fset.Position(decl.End())
 	symbol := &Symbol{
 		Package:  pkgName,
 		Name:     decl.Name.Name,
 		Exported: ast.IsExported(decl.Name.Name),
 		Position: fmt.Sprintf("%s:%d", filepath.Base(filePath), pos.Line),
+		File:     filePath,
+		Line:     pos.Line,
+		EndLine:  end.Line,
 	}
 
 	if decl.Recv != nil && len(decl.Recv.List) > 0 {
@@ -89,23 +141,65 @@ func extractFunction(decl *ast.FuncDecl, pkgName string, fset *token.FileSet, fi
 		symbol.Receiver = extractReceiverType(decl.Recv.List[0].Type)
 	} else {
 		symbol.Kind = "func"
+		symbol.PackageWide = decl.Name.Name == "init" ||
+			(decl.Name.Name == "TestMain" && strings.HasSuffix(filePath, "_test.go"))
 	}
 
 	return symbol
 }
 
+// extractVars records package-level variables that have initializers. The
+// initializer runs before any test, so a change to it is package-wide.
+func extractVars(spec *ast.ValueSpec, pkgName string, fset *token.FileSet, filePath string) []Symbol {
+	if len(spec.Values) == 0 {
+		return nil
+	}
+
+	pos := fset.Position(spec.Pos())
+	end := fset.Position(spec.End())
+
+	var result []Symbol
+	for _, name := range spec.Names {
+		if name.Name == "_" {
+			continue
+		}
+		result = append(result, Symbol{
+			Package:     pkgName,
+			Name:        name.Name,
+			Kind:        "var",
+			Exported:    ast.IsExported(name.Name),
+			Position:    fmt.Sprintf("%s:%d", filepath.Base(filePath), pos.Line),
+			File:        filePath,
+			Line:        pos.Line,
+			EndLine:     end.Line,
+			PackageWide: true,
+		})
+	}
+
+	return result
+}
+
+// extractReceiverType returns the receiver's type name without type
+// parameters, prefixed with "*" for pointer receivers: (s *Set[K, V]) yields
+// "*Set".
 func extractReceiverType(expr ast.Expr) string {
 	switch t := expr.(type) {
 	case *ast.Ident:
 		return t.Name
+	case *ast.ParenExpr:
+		return extractReceiverType(t.X)
 	case *ast.StarExpr:
-		if ident, ok := t.X.(*ast.Ident); ok {
-			return "*" + ident.Name
+		if name := extractReceiverType(t.X); name != "" && !strings.HasPrefix(name, "*") {
+			return "*" + name
 		}
 	case *ast.IndexExpr:
 		if ident, ok := t.X.(*ast.Ident); ok {
 			return ident.Name
 		}
+	case *ast.IndexListExpr:
+		if ident, ok := t.X.(*ast.Ident); ok {
+			return ident.Name
+		}
 	}
 	return ""
 }
@@ -116,28 +210,90 @@ func extractType(spec *ast.TypeSpec, pkgName string, fset *token.FileSet, filePa
 	}
 
 	pos := fset.Position(spec.Pos())
+	end := fset.Position(spec.End())
+	// A struct type spans only its header; each field is a symbol of its own,
+	// so changing a field does not change every use of the type.
+	if st, ok := spec.Type.(*ast.StructType); ok && st.Fields != nil && st.Fields.Opening.IsValid() {
+		end = fset.Position(st.Fields.Opening)
+	}
 	symbol := &Symbol{
 		Package:  pkgName,
 		Name:     spec.Name.Name,
 		Kind:     "type",
 		Exported: ast.IsExported(spec.Name.Name),
 		Position: fmt.Sprintf("%s:%d", filepath.Base(filePath), pos.Line),
+		File:     filePath,
+		Line:     pos.Line,
+		EndLine:  end.Line,
 	}
 
 	return symbol
 }
 
-func getPackageImportPath(filePath string) string {
-	dir := filepath.Dir(filePath)
+// extractFields records the fields of a struct type. Embedded fields are
+// named after their type, as in the type checker.
+func extractFields(spec *ast.TypeSpec, pkgName string, fset *token.FileSet, filePath string) []Symbol {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || spec.Name == nil || st.Fields == nil {
+		return nil
+	}
 
-	var listPath string
-	if filepath.IsAbs(dir) {
-		listPath = dir
-	} else {
-		listPath = "./" + dir
+	var result []Symbol
+	for _, field := range st.Fields.List {
+		pos := fset.Position(field.Pos())
+		end := fset.Position(field.End())
+
+		names := field.Names
+		if len(names) == 0 {
+			if name := embeddedFieldName(field.Type); name != "" {
+				names = []*ast.Ident{ast.NewIdent(name)}
+			}
+		}
+
+		for _, name := range names {
+			if name.Name == "_" {
+				continue
+			}
+			result = append(result, Symbol{
+				Package:  pkgName,
+				Name:     name.Name,
+				Kind:     "field",
+				Receiver: spec.Name.Name,
+				Exported: ast.IsExported(name.Name),
+				Position: fmt.Sprintf("%s:%d", filepath.Base(filePath), pos.Line),
+				File:     filePath,
+				Line:     pos.Line,
+				EndLine:  end.Line,
+			})
+		}
 	}
 
-	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", listPath)
+	return result
+}
+
+func embeddedFieldName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedFieldName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedFieldName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedFieldName(t.X)
+	}
+	return ""
+}
+
+func getPackageImportPath(filePath string) string {
+	dir := filepath.Dir(filePath)
+
+	// Run from the package directory so the owning module is resolved even
+	// when it is not the module of the current directory.
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
 		return filepath.Base(dir)
@@ -158,6 +314,12 @@ func FormatSymbols(symbols []Symbol) string {
 		if sym.Exported {
 			visibility = "exported"
 		}
+		if sym.PackageWide {
+			visibility += ", package-wide"
+		}
+		if sym.Generated {
+			visibility += ", generated"
+		}
 
 		switch sym.Kind {
 		case "func":
@@ -169,6 +331,15 @@ func FormatSymbols(symbols []Symbol) string {
 		case "type":
 			sb.WriteString(fmt.Sprintf("[%s] type %s.%s at %s\n",
 				visibility, sym.Package, sym.Name, sym.Position))
+		case "var":
+			sb.WriteString(fmt.Sprintf("[%s] var %s.%s at %s\n",
+				visibility, sym.Package, sym.Name, sym.Position))
+		case "field":
+			sb.WriteString(fmt.Sprintf("[%s] field %s.%s.%s at %s\n",
+				visibility, sym.Package, sym.Receiver, sym.Name, sym.Position))
+		case "generate":
+			sb.WriteString(fmt.Sprintf("[%s] go:generate input %s of %s at %s\n",
+				visibility, sym.File, sym.Package, sym.Position))
 		}
 	}
 
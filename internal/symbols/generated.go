@@ -0,0 +1,39 @@
+package symbols
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+)
+
+var sourceLine = regexp.MustCompile(`^//\s*[Ss]ource:\s*(\S+)`)
+
+// GeneratorSource returns the generator input named in the header of a
+// generated file, such as the "// source: api.proto" line written by
+// protoc-gen-go and sqlc or "// Source: store.go" written by mockgen.
+func GeneratorSource(src []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if bytes.HasPrefix(line, []byte("package ")) {
+			break
+		}
+		if m := sourceLine.FindSubmatch(line); m != nil {
+			return string(m[1])
+		}
+	}
+	return ""
+}
+
+// IsGenerated reports whether src carries the standard
+// "// Code generated ... DO NOT EDIT." comment.
+func IsGenerated(path string, src []byte) bool {
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	return ast.IsGenerated(file)
+}
@@ -11,18 +11,24 @@ import (
 )
 
 type Test struct {
-	Package  string
-	Name     string
-	FileName string
-	FilePath string
-	Position string
+	Package  string `json:"package"`
+	Name     string `json:"name"`
+	FileName string `json:"file_name"`
+	FilePath string `json:"file_path"`
+	Position string `json:"position"`
 }
 
 func DiscoverFromPackages(packages []string) ([]Test, error) {
+	return DiscoverFromPackagesInDir("", packages)
+}
+
+// DiscoverFromPackagesInDir resolves packages from dir, which should be the
+// root of the module they belong to.
+func DiscoverFromPackagesInDir(dir string, packages []string) ([]Test, error) {
 	var allTests []Test
 
 	for _, pkg := range packages {
-		testFiles, err := findTestFilesInPackage(pkg)
+		testFiles, err := findTestFilesInPackage(dir, pkg)
 		if err != nil {
 			continue
 		}
@@ -39,8 +45,9 @@ func DiscoverFromPackages(packages []string) ([]Test, error) {
 	return allTests, nil
 }
 
-func findTestFilesInPackage(packagePath string) ([]string, error) {
+func findTestFilesInPackage(workDir, packagePath string) ([]string, error) {
 	cmd := exec.Command("go", "list", "-f", "{{.Dir}}", packagePath)
+	cmd.Dir = workDir
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -105,6 +112,12 @@ func isTestFunction(decl *ast.FuncDecl) bool {
 		return false
 	}
 
+	// TestMain is the package's test entry point, not a test; it cannot be
+	// selected with -run.
+	if name == "TestMain" {
+		return false
+	}
+
 	if decl.Type.Params == nil || len(decl.Type.Params.List) == 0 {
 		return false
 	}
@@ -134,14 +147,10 @@ func extractTest(decl *ast.FuncDecl, packagePath string, fset *token.FileSet, fi
 func getPackageImportPath(filePath string) string {
 	dir := filepath.Dir(filePath)
 
-	var listPath string
-	if filepath.IsAbs(dir) {
-		listPath = dir
-	} else {
-		listPath = "./" + dir
-	}
-
-	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", listPath)
+	// Run from the package directory so the owning module is resolved even
+	// when it is not the module of the current directory.
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".")
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
 		return filepath.Base(dir)
@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Cache keeps loaded packages between DetectUsages calls, so long-running
+// modes such as watch do not pay for type-checking unchanged packages again.
+// A nil *Cache loads packages on every call.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string][]*packages.Package
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: make(map[string][]*packages.Package),
+	}
+}
+
+func (c *Cache) load(cfg *packages.Config, pattern string) ([]*packages.Package, error) {
+	if c == nil {
+		return packages.Load(cfg, pattern)
+	}
+
+	key := fmt.Sprintf("%s|%s|%t", cfg.Dir, pattern, cfg.Tests)
+
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = pkgs
+	c.mu.Unlock()
+
+	return pkgs, nil
+}
+
+// Invalidate drops cached packages that are, or transitively import, any of
+// the changed packages.
+func (c *Cache) Invalidate(changedPackages []string) {
+	if c == nil || len(changedPackages) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, pkgs := range c.entries {
+		if dependsOnAny(pkgs, changedPackages) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func dependsOnAny(pkgs []*packages.Package, changedPackages []string) bool {
+	for _, pkg := range pkgs {
+		for _, changed := range changedPackages {
+			if pkg.PkgPath == changed {
+				return true
+			}
+			if pkg.Types != nil && findImportedPackage(pkg.Types, changed) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
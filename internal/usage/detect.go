@@ -13,17 +13,41 @@ import (
 	"jombG/goblast/internal/tests"
 )
 
+const (
+	ViaDirect    = "direct"
+	ViaHelper    = "helper"
+	ViaInterface = "interface"
+	// ViaImplementation marks tests using a type that implements a changed
+	// interface, typically a mock.
+	ViaImplementation = "implementation"
+)
+
 type Usage struct {
-	TestName   string
-	TestFile   string
-	SymbolName string
-	SymbolKind string
+	TestName string `json:"test_name"`
+	// TestPackage is the import path of the test's package, since test
+	// names are only unique within a package.
+	TestPackage string `json:"test_package"`
+	TestFile    string `json:"test_file"`
+	SymbolName  string `json:"symbol_name"`
+	SymbolKind  string `json:"symbol_kind"`
+	// Via describes how the test reaches the symbol. Usages through an
+	// interface are potential usages and carry lower confidence.
+	Via string `json:"via"`
+	// Through names the helper, interface method or implementing type the
+	// symbol was reached by.
+	Through string `json:"through"`
 }
 
 func DetectUsages(discoveredTests []tests.Test, changedSymbols []symbols.Symbol) ([]Usage, error) {
+	return DetectUsagesCached(discoveredTests, changedSymbols, nil)
+}
+
+// DetectUsagesCached is DetectUsages reusing packages loaded into cache by
+// earlier calls.
+func DetectUsagesCached(discoveredTests []tests.Test, changedSymbols []symbols.Symbol, cache *Cache) ([]Usage, error) {
 	var usages []Usage
 
-	symbolObjects, err := resolveSymbolObjects(changedSymbols)
+	symbolObjects, err := resolveSymbolObjects(changedSymbols, cache)
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +55,7 @@ func DetectUsages(discoveredTests []tests.Test, changedSymbols []symbols.Symbol)
 	testsByPackage := groupTestsByPackage(discoveredTests)
 
 	for pkgPath, pkgTests := range testsByPackage {
-		pkgUsages, err := detectUsagesInPackage(pkgPath, pkgTests, symbolObjects, changedSymbols)
+		pkgUsages, err := detectUsagesInPackage(pkgPath, pkgTests, symbolObjects, changedSymbols, cache)
 		if err != nil {
 			continue
 		}
@@ -41,7 +65,7 @@ func DetectUsages(discoveredTests []tests.Test, changedSymbols []symbols.Symbol)
 	return usages, nil
 }
 
-func resolveSymbolObjects(changedSymbols []symbols.Symbol) (map[types.Object]symbols.Symbol, error) {
+func resolveSymbolObjects(changedSymbols []symbols.Symbol, cache *Cache) (map[types.Object]symbols.Symbol, error) {
 	result := make(map[types.Object]symbols.Symbol)
 
 	pkgSymbols := make(map[string][]symbols.Symbol)
@@ -57,8 +81,11 @@ func resolveSymbolObjects(changedSymbols []symbols.Symbol) (map[types.Object]sym
 		cfg := &packages.Config{
 			Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
 		}
+		if syms[0].File != "" {
+			cfg.Dir = filepath.Dir(syms[0].File)
+		}
 
-		pkgs, err := packages.Load(cfg, pkgName)
+		pkgs, err := cache.load(cfg, pkgName)
 		if err != nil || len(pkgs) == 0 {
 			continue
 		}
@@ -117,62 +144,71 @@ func groupTestsByPackage(testList []tests.Test) map[string][]tests.Test {
 	return result
 }
 
-func detectUsagesInPackage(pkgPath string, pkgTests []tests.Test, symbolObjects map[types.Object]symbols.Symbol, changedSymbols []symbols.Symbol) ([]Usage, error) {
+func detectUsagesInPackage(pkgPath string, pkgTests []tests.Test, symbolObjects map[types.Object]symbols.Symbol, changedSymbols []symbols.Symbol, cache *Cache) ([]Usage, error) {
 	var usages []Usage
 
 	cfg := &packages.Config{
 		Mode:  packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
 		Tests: true,
 	}
+	if len(pkgTests) > 0 && pkgTests[0].FilePath != "" {
+		cfg.Dir = filepath.Dir(pkgTests[0].FilePath)
+	}
 
-	pkgs, err := packages.Load(cfg, pkgPath)
+	pkgs, err := cache.load(cfg, pkgPath)
 	if err != nil || len(pkgs) == 0 {
 		return nil, err
 	}
 
-	var testPkg *packages.Package
+	// Internal and external test files are type-checked as separate
+	// packages, so every package carrying test files is considered.
+	var testPkgs []*packages.Package
 	for _, pkg := range pkgs {
-		if pkg.TypesInfo == nil {
-			continue
-		}
-
-		hasTestFiles := false
-		for _, file := range pkg.Syntax {
-			fileName := filepath.Base(pkg.Fset.File(file.Pos()).Name())
-			if strings.HasSuffix(fileName, "_test.go") {
-				hasTestFiles = true
-				break
-			}
-		}
-		if hasTestFiles {
-			testPkg = pkg
-			break
+		if pkg.TypesInfo != nil && hasTestFiles(pkg) {
+			testPkgs = append(testPkgs, pkg)
 		}
 	}
 
 	// Fallback: use any package with type info
-	if testPkg == nil {
+	if len(testPkgs) == 0 {
 		for _, pkg := range pkgs {
 			if pkg.TypesInfo != nil {
-				testPkg = pkg
+				testPkgs = append(testPkgs, pkg)
 				break
 			}
 		}
 	}
 
-	if testPkg == nil || testPkg.TypesInfo == nil {
+	if len(testPkgs) == 0 {
 		return nil, fmt.Errorf("no type info for package %s", pkgPath)
 	}
 
+	dispatches := make(map[*packages.Package]*dispatchIndex)
+	implementers := make(map[*packages.Package]*implementerIndex)
+	helpers := make(map[*packages.Package]*testHelpers)
+	for _, pkg := range testPkgs {
+		dispatches[pkg] = newDispatchIndex(pkg.Types, changedSymbols)
+		implementers[pkg] = newImplementerIndex(pkg.Types, changedSymbols)
+		helpers[pkg] = newTestHelpers(pkg)
+	}
+
 	for _, test := range pkgTests {
-		testUsages := findUsagesInTest(testPkg, test, changedSymbols)
+		testPkg := testPkgs[0]
+		for _, pkg := range testPkgs {
+			if containsFile(pkg, test.FileName) {
+				testPkg = pkg
+				break
+			}
+		}
+
+		testUsages := findUsagesInTest(testPkg, test, changedSymbols, dispatches[testPkg], implementers[testPkg], helpers[testPkg])
 		usages = append(usages, testUsages...)
 	}
 
 	return usages, nil
 }
 
-func findUsagesInTest(pkg *packages.Package, test tests.Test, changedSymbols []symbols.Symbol) []Usage {
+func findUsagesInTest(pkg *packages.Package, test tests.Test, changedSymbols []symbols.Symbol, dispatch *dispatchIndex, implementers *implementerIndex, helpers *testHelpers) []Usage {
 	var usages []Usage
 
 	if debugUsageDetection {
@@ -225,53 +261,206 @@ func findUsagesInTest(pkg *packages.Package, test tests.Test, changedSymbols []s
 		}
 	}
 
-	ast.Inspect(testFunc.Body, func(n ast.Node) bool {
-		switch node := n.(type) {
-		case *ast.Ident:
-			if obj := pkg.TypesInfo.Uses[node]; obj != nil {
-				if sym, found := matchSymbol(obj, symbolLookup); found {
-					usages = append(usages, Usage{
-						TestName:   test.Name,
-						TestFile:   test.Position,
-						SymbolName: sym.Name,
-						SymbolKind: sym.Kind,
-					})
+	addUsage := func(sym symbols.Symbol, via, through string) {
+		name := sym.Name
+		if sym.Kind == "field" {
+			name = sym.Receiver + "." + sym.Name
+		}
+		usages = append(usages, Usage{
+			TestName:    test.Name,
+			TestPackage: test.Package,
+			TestFile:    test.Position,
+			SymbolName:  name,
+			SymbolKind:  sym.Kind,
+			Via:         via,
+			Through:     through,
+		})
+	}
+
+	checkObject := func(obj types.Object, through string) {
+		if sym, found := matchSymbol(originObject(obj), symbolLookup); found {
+			if through == "" {
+				addUsage(sym, ViaDirect, "")
+			} else {
+				addUsage(sym, ViaHelper, through)
+			}
+			return
+		}
+		for _, sym := range dispatch.match(obj) {
+			addUsage(sym, ViaInterface, obj.(*types.Func).FullName())
+		}
+		for _, impl := range implementers.match(obj) {
+			addUsage(impl.sym, ViaImplementation, impl.typeName)
+		}
+	}
+
+	// Walk the test body, then every helper it reaches. References found in a
+	// helper are attributed to the test under the name of the helper the test
+	// called. TestMain runs before every test, so it is always walked.
+	type pendingBody struct {
+		body    *ast.BlockStmt
+		through string
+	}
+
+	queue := []pendingBody{{body: testFunc.Body}}
+	visited := map[*ast.FuncDecl]bool{testFunc: true}
+	if helpers.testMain != nil && helpers.testMain != testFunc {
+		visited[helpers.testMain] = true
+		queue = append(queue, pendingBody{body: helpers.testMain.Body, through: "TestMain"})
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		visitField := func(owner *types.TypeName, name string) {
+			if owner == nil || owner.Pkg() == nil {
+				return
+			}
+			key := fmt.Sprintf("%s::%s.%s::field", owner.Pkg().Path(), owner.Name(), name)
+			if sym, ok := symbolLookup[key]; ok {
+				if current.through == "" {
+					addUsage(sym, ViaDirect, "")
+				} else {
+					addUsage(sym, ViaHelper, current.through)
 				}
 			}
-		case *ast.SelectorExpr:
-			if sel := pkg.TypesInfo.Selections[node]; sel != nil {
-				if obj := sel.Obj(); obj != nil {
-					if sym, found := matchSymbol(obj, symbolLookup); found {
-						usages = append(usages, Usage{
-							TestName:   test.Name,
-							TestFile:   test.Position,
-							SymbolName: sym.Name,
-							SymbolKind: sym.Kind,
-						})
+		}
+
+		visit := func(obj types.Object) {
+			checkObject(obj, current.through)
+
+			decl := helpers.lookup(obj)
+			if decl == nil || visited[decl] {
+				return
+			}
+			visited[decl] = true
+
+			through := current.through
+			if through == "" {
+				through = decl.Name.Name
+			}
+			queue = append(queue, pendingBody{body: decl.Body, through: through})
+		}
+
+		ast.Inspect(current.body, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.Ident:
+				if obj := pkg.TypesInfo.Uses[node]; obj != nil {
+					visit(obj)
+				}
+			case *ast.SelectorExpr:
+				if sel := pkg.TypesInfo.Selections[node]; sel != nil {
+					if obj := sel.Obj(); obj != nil {
+						visit(obj)
 					}
 				}
-			}
-			if obj := pkg.TypesInfo.Uses[node.Sel]; obj != nil {
-				if sym, found := matchSymbol(obj, symbolLookup); found {
-					usages = append(usages, Usage{
-						TestName:   test.Name,
-						TestFile:   test.Position,
-						SymbolName: sym.Name,
-						SymbolKind: sym.Kind,
-					})
+				if obj := pkg.TypesInfo.Uses[node.Sel]; obj != nil {
+					visit(obj)
+				}
+				visitField(fieldOwner(pkg.TypesInfo, node), node.Sel.Name)
+			case *ast.CompositeLit:
+				owner := structTypeName(pkg.TypesInfo.TypeOf(node))
+				if owner != nil && owner.Pkg() != nil && isUnkeyed(node) {
+					// Positional literals list every field, so any change
+					// to the struct's fields affects them.
+					for _, sym := range changedSymbols {
+						if sym.Kind == "field" && sym.Package == owner.Pkg().Path() && sym.Receiver == owner.Name() {
+							visitField(owner, sym.Name)
+						}
+					}
+				}
+				for _, elt := range node.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok {
+							visitField(owner, key.Name)
+						}
+					}
 				}
 			}
-		}
-		return true
-	})
+			return true
+		})
+	}
 
 	return deduplicateUsages(usages)
 }
 
 func makeSymbolKey(sym symbols.Symbol) string {
+	if sym.Kind == "field" {
+		return fmt.Sprintf("%s::%s.%s::%s", sym.Package, sym.Receiver, sym.Name, sym.Kind)
+	}
 	return fmt.Sprintf("%s::%s::%s", sym.Package, sym.Name, sym.Kind)
 }
 
+// originObject maps functions, methods and fields of instantiated generics to
+// their generic declarations, which are what changed symbols refer to.
+func originObject(obj types.Object) types.Object {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin()
+	case *types.Var:
+		return o.Origin()
+	}
+	return obj
+}
+
+// fieldOwner returns the struct type declaring the field selected by sel,
+// following embedded fields for promoted ones. When the selection did not
+// type-check, as for a field that was just removed, the operand's struct type
+// is returned so the removed field can still be matched by name.
+func fieldOwner(info *types.Info, sel *ast.SelectorExpr) *types.TypeName {
+	selection := info.Selections[sel]
+	if selection == nil {
+		return structTypeName(info.TypeOf(sel.X))
+	}
+	if selection.Kind() != types.FieldVal {
+		return nil
+	}
+
+	t := selection.Recv()
+	index := selection.Index()
+	for _, i := range index[:len(index)-1] {
+		st, ok := derefType(t).Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t = st.Field(i).Type()
+	}
+	return structTypeName(t)
+}
+
+// structTypeName returns the declared name of a named struct type or a
+// pointer to one.
+func structTypeName(t types.Type) *types.TypeName {
+	if t == nil {
+		return nil
+	}
+	named, ok := derefType(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil
+	}
+	return named.Origin().Obj()
+}
+
+// isUnkeyed reports whether lit lists its elements by position.
+func isUnkeyed(lit *ast.CompositeLit) bool {
+	if len(lit.Elts) == 0 {
+		return false
+	}
+	_, keyed := lit.Elts[0].(*ast.KeyValueExpr)
+	return !keyed
+}
+
+func derefType(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
 var debugUsageDetection = false
 
 func matchSymbol(obj types.Object, lookup map[string]symbols.Symbol) (symbols.Symbol, bool) {
@@ -312,16 +501,29 @@ func matchSymbol(obj types.Object, lookup map[string]symbols.Symbol) (symbols.Sy
 	return sym, found
 }
 
+var viaRank = map[string]int{
+	ViaDirect:         0,
+	ViaHelper:         1,
+	ViaImplementation: 2,
+	ViaInterface:      3,
+}
+
+// deduplicateUsages keeps one usage per test and symbol, preferring the most
+// direct way the test reaches it.
 func deduplicateUsages(usages []Usage) []Usage {
-	seen := make(map[string]bool)
+	seen := make(map[string]int)
 	var unique []Usage
 
 	for _, usage := range usages {
-		key := fmt.Sprintf("%s:%s:%s", usage.TestName, usage.SymbolName, usage.SymbolKind)
-		if !seen[key] {
-			seen[key] = true
-			unique = append(unique, usage)
+		key := fmt.Sprintf("%s:%s:%s:%s", usage.TestPackage, usage.TestName, usage.SymbolName, usage.SymbolKind)
+		if i, ok := seen[key]; ok {
+			if viaRank[usage.Via] < viaRank[unique[i].Via] {
+				unique[i] = usage
+			}
+			continue
 		}
+		seen[key] = len(unique)
+		unique = append(unique, usage)
 	}
 
 	return unique
@@ -345,6 +547,18 @@ func FormatUsages(usages []Usage) string {
 	for testKey, usages := range testUsages {
 		sb.WriteString(fmt.Sprintf("Test: %s\n", testKey))
 		for _, usage := range usages {
+			if usage.Via == ViaHelper {
+				sb.WriteString(fmt.Sprintf("  - uses %s %s via helper %s\n", usage.SymbolKind, usage.SymbolName, usage.Through))
+				continue
+			}
+			if usage.Via == ViaImplementation {
+				sb.WriteString(fmt.Sprintf("  - uses %s %s via implementation %s\n", usage.SymbolKind, usage.SymbolName, usage.Through))
+				continue
+			}
+			if usage.Via == ViaInterface {
+				sb.WriteString(fmt.Sprintf("  - may use %s %s via %s (low confidence)\n", usage.SymbolKind, usage.SymbolName, usage.Through))
+				continue
+			}
 			sb.WriteString(fmt.Sprintf("  - uses %s %s\n", usage.SymbolKind, usage.SymbolName))
 		}
 		sb.WriteString("\n")
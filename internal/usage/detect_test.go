@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/tests"
+)
+
+func TestFindUsagesStructFields(t *testing.T) {
+	sources := map[string]string{
+		"product.go": `package shop
+
+type Product struct {
+	ID    int
+	Name  string
+	Price float64
+	Stock int
+}
+`,
+		"product_test.go": `package shop
+
+func TestPositional() {
+	_ = Product{1, "tea", 2.5}
+}
+
+func TestKeyed() {
+	_ = Product{ID: 1, Name: "tea"}
+}
+
+func TestSelector() {
+	var p Product
+	_ = p.Stock
+}
+
+func TestEmpty() {
+	_ = Product{}
+}
+`,
+	}
+
+	pkg := typeCheck(t, "example.com/shop", sources)
+	changed := []symbols.Symbol{{
+		Package:  "example.com/shop",
+		Name:     "Stock",
+		Kind:     "field",
+		Receiver: "Product",
+	}}
+
+	cases := []struct {
+		test string
+		want []string
+	}{
+		// The positional literal predates Stock, so it no longer compiles.
+		{"TestPositional", []string{"Product.Stock"}},
+		{"TestKeyed", nil},
+		{"TestSelector", []string{"Product.Stock"}},
+		{"TestEmpty", nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.test, func(t *testing.T) {
+			test := tests.Test{Package: "example.com/shop", Name: tt.test, FileName: "product_test.go"}
+			usages := findUsagesInTest(pkg, test, changed,
+				newDispatchIndex(pkg.Types, changed), newImplementerIndex(pkg.Types, changed), newTestHelpers(pkg))
+
+			var got []string
+			for _, u := range usages {
+				got = append(got, u.SymbolName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("findUsagesInTest(%s) = %v; want %v", tt.test, got, tt.want)
+			}
+		})
+	}
+}
+
+// typeCheck builds a package from sources without loading dependencies,
+// keeping type information for code that no longer compiles.
+func typeCheck(t *testing.T, path string, sources map[string]string) *packages.Package {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, src := range sources {
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{Error: func(error) {}}
+	typesPkg, _ := conf.Check(path, fset, files, info)
+
+	return &packages.Package{
+		PkgPath:   path,
+		Fset:      fset,
+		Syntax:    files,
+		Types:     typesPkg,
+		TypesInfo: info,
+	}
+}
@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"go/types"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// dispatchIndex links interface methods to the changed concrete methods that
+// implement them, so calls made through an interface can be attributed to
+// the implementation that changed.
+type dispatchIndex struct {
+	methods []concreteMethod
+	cache   map[*types.Func][]symbols.Symbol
+}
+
+type concreteMethod struct {
+	sym  symbols.Symbol
+	recv types.Type
+}
+
+func newDispatchIndex(root *types.Package, changedSymbols []symbols.Symbol) *dispatchIndex {
+	index := &dispatchIndex{
+		cache: make(map[*types.Func][]symbols.Symbol),
+	}
+	if root == nil {
+		return index
+	}
+
+	for _, sym := range changedSymbols {
+		if sym.Kind != "method" || sym.Receiver == "" {
+			continue
+		}
+
+		pkg := findImportedPackage(root, sym.Package)
+		if pkg == nil {
+			continue
+		}
+
+		typeName, ok := pkg.Scope().Lookup(strings.TrimPrefix(sym.Receiver, "*")).(*types.TypeName)
+		if !ok || types.IsInterface(typeName.Type()) || isGeneric(typeName.Type()) {
+			continue
+		}
+
+		index.methods = append(index.methods, concreteMethod{
+			sym:  sym,
+			recv: typeName.Type(),
+		})
+	}
+
+	return index
+}
+
+// match returns the changed methods that obj may dispatch to when obj is an
+// interface method.
+func (d *dispatchIndex) match(obj types.Object) []symbols.Symbol {
+	fn, ok := obj.(*types.Func)
+	if !ok || len(d.methods) == 0 {
+		return nil
+	}
+
+	if cached, ok := d.cache[fn]; ok {
+		return cached
+	}
+
+	var matched []symbols.Symbol
+	if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+		if iface, ok := sig.Recv().Type().Underlying().(*types.Interface); ok {
+			for _, m := range d.methods {
+				if m.sym.Name != fn.Name() {
+					continue
+				}
+				if types.Implements(m.recv, iface) || types.Implements(types.NewPointer(m.recv), iface) {
+					matched = append(matched, m.sym)
+				}
+			}
+		}
+	}
+
+	d.cache[fn] = matched
+	return matched
+}
+
+// isGeneric reports whether t is a generic type that has not been
+// instantiated. types.Implements is unspecified for such types.
+func isGeneric(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.TypeParams().Len() > 0 && named.TypeArgs().Len() == 0
+}
+
+func findImportedPackage(root *types.Package, path string) *types.Package {
+	seen := make(map[*types.Package]bool)
+	queue := []*types.Package{root}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+
+		if pkg.Path() == path {
+			return pkg
+		}
+		queue = append(queue, pkg.Imports()...)
+	}
+
+	return nil
+}
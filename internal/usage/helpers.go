@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// testHelpers indexes the functions and methods declared in the test files of
+// a package, so references made inside shared helpers and TestMain can be
+// attributed to the tests that call them.
+type testHelpers struct {
+	decls    map[types.Object]*ast.FuncDecl
+	testMain *ast.FuncDecl
+}
+
+func newTestHelpers(pkg *packages.Package) *testHelpers {
+	helpers := &testHelpers{
+		decls: make(map[types.Object]*ast.FuncDecl),
+	}
+
+	for _, file := range pkg.Syntax {
+		fileName := filepath.Base(pkg.Fset.File(file.Pos()).Name())
+		if !strings.HasSuffix(fileName, "_test.go") {
+			continue
+		}
+
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Name == nil || funcDecl.Body == nil {
+				continue
+			}
+
+			if funcDecl.Recv == nil && funcDecl.Name.Name == "TestMain" {
+				helpers.testMain = funcDecl
+				continue
+			}
+
+			if obj := pkg.TypesInfo.Defs[funcDecl.Name]; obj != nil {
+				helpers.decls[obj] = funcDecl
+			}
+		}
+	}
+
+	return helpers
+}
+
+// lookup returns the helper declaration obj refers to, if any.
+func (h *testHelpers) lookup(obj types.Object) *ast.FuncDecl {
+	if fn, ok := obj.(*types.Func); ok {
+		obj = fn.Origin()
+	}
+	return h.decls[obj]
+}
+
+func hasTestFiles(pkg *packages.Package) bool {
+	for _, file := range pkg.Syntax {
+		fileName := filepath.Base(pkg.Fset.File(file.Pos()).Name())
+		if strings.HasSuffix(fileName, "_test.go") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFile(pkg *packages.Package, fileName string) bool {
+	for _, file := range pkg.Syntax {
+		if filepath.Base(pkg.Fset.File(file.Pos()).Name()) == fileName {
+			return true
+		}
+	}
+	return false
+}
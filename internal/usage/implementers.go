@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"go/types"
+	"strings"
+
+	"jombG/goblast/internal/symbols"
+)
+
+// implementerIndex links types to the changed interfaces they implement, such
+// as mockgen, testify or hand-written mocks. Tests using an implementation
+// exercise code written against the interface without naming it.
+type implementerIndex struct {
+	interfaces []changedInterface
+	cache      map[types.Object][]implementation
+}
+
+type changedInterface struct {
+	sym   symbols.Symbol
+	iface *types.Interface
+}
+
+type implementation struct {
+	sym      symbols.Symbol
+	typeName string
+}
+
+// mockAffixes mark types named after the interface they stand in for, like
+// MockStore or fakeStore. Such types are matched even when they no longer
+// satisfy a changed interface, e.g. a mock not yet regenerated.
+var mockAffixes = []string{"mock", "fake", "stub"}
+
+func newImplementerIndex(root *types.Package, changedSymbols []symbols.Symbol) *implementerIndex {
+	index := &implementerIndex{
+		cache: make(map[types.Object][]implementation),
+	}
+	if root == nil {
+		return index
+	}
+
+	for _, sym := range changedSymbols {
+		if sym.Kind != "type" {
+			continue
+		}
+
+		pkg := findImportedPackage(root, sym.Package)
+		if pkg == nil {
+			continue
+		}
+
+		typeName, ok := pkg.Scope().Lookup(sym.Name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		iface, ok := typeName.Type().Underlying().(*types.Interface)
+		// Every type implements an empty interface.
+		if !ok || iface.NumMethods() == 0 {
+			continue
+		}
+
+		index.interfaces = append(index.interfaces, changedInterface{
+			sym:   sym,
+			iface: iface,
+		})
+	}
+
+	return index
+}
+
+// match returns the changed interfaces implemented by the type obj refers to:
+// the type itself for a type name, the receiver for a method, the results for
+// a function such as a mock constructor, or the type of a variable.
+func (x *implementerIndex) match(obj types.Object) []implementation {
+	if len(x.interfaces) == 0 || obj == nil {
+		return nil
+	}
+
+	if cached, ok := x.cache[obj]; ok {
+		return cached
+	}
+
+	var candidates []types.Type
+	switch o := obj.(type) {
+	case *types.TypeName:
+		candidates = append(candidates, o.Type())
+	case *types.Func:
+		sig, ok := o.Type().(*types.Signature)
+		if !ok {
+			break
+		}
+		if sig.Recv() != nil {
+			candidates = append(candidates, sig.Recv().Type())
+			break
+		}
+		for i := 0; i < sig.Results().Len(); i++ {
+			candidates = append(candidates, sig.Results().At(i).Type())
+		}
+	case *types.Var:
+		if !o.IsField() {
+			candidates = append(candidates, o.Type())
+		}
+	}
+
+	var matched []implementation
+	seen := make(map[string]bool)
+	for _, t := range candidates {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		named, ok := t.(*types.Named)
+		if !ok || types.IsInterface(named) {
+			continue
+		}
+
+		for _, ci := range x.interfaces {
+			key := makeSymbolKey(ci.sym) + "|" + named.Obj().Name()
+			if seen[key] {
+				continue
+			}
+			implements := !isGeneric(named) && (types.Implements(named, ci.iface) || types.Implements(types.NewPointer(named), ci.iface))
+			if implements || isMockOf(named.Obj().Name(), ci.sym.Name) {
+				seen[key] = true
+				matched = append(matched, implementation{sym: ci.sym, typeName: named.Obj().Name()})
+			}
+		}
+	}
+
+	x.cache[obj] = matched
+	return matched
+}
+
+// isMockOf reports whether typeName is ifaceName with a mock affix, ignoring
+// case: MockStore, mockStore, StoreMock and FakeStore all match Store.
+func isMockOf(typeName, ifaceName string) bool {
+	lower := strings.ToLower(typeName)
+	target := strings.ToLower(ifaceName)
+
+	for _, affix := range mockAffixes {
+		if strings.TrimPrefix(lower, affix) == target && lower != target {
+			return true
+		}
+		if strings.TrimSuffix(lower, affix) == target && lower != target {
+			return true
+		}
+	}
+	return false
+}
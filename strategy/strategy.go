@@ -0,0 +1,40 @@
+// Package strategy lets programs built on goblast add their own test selection
+// strategies, such as organization-specific rules. Register a strategy before
+// calling cli.Main, typically from an init function; it is then accepted by
+// -strategy and by composite configurations like the built-in ones:
+//
+//	func init() {
+//		strategy.Register("contracts", func() strategy.Strategy { return &contracts{} })
+//	}
+//
+//	func main() {
+//		cli.Main()
+//	}
+package strategy
+
+import (
+	"jombG/goblast/internal/selector"
+	"jombG/goblast/internal/symbols"
+	"jombG/goblast/internal/tests"
+	"jombG/goblast/internal/usage"
+)
+
+type (
+	// Strategy selects the tests to run for a set of changed symbols.
+	Strategy = selector.Strategy
+	TestID   = selector.TestID
+	Symbol   = symbols.Symbol
+	Test     = tests.Test
+	Usage    = usage.Usage
+)
+
+// Register makes a strategy available under name. It panics if name is
+// already registered.
+func Register(name string, factory func() Strategy) {
+	selector.Register(name, factory)
+}
+
+// Names returns the registered strategy names in sorted order.
+func Names() []string {
+	return selector.Names()
+}